@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// runExportAudit выгружает журнал аудита в формате JSON Lines (одно событие на строку)
+func runExportAudit(db *database.DB, path, since string) error {
+	var f models.AuditFilter
+	if since != "" {
+		t, err := time.Parse("2006-01-02", since)
+		if err != nil {
+			return err
+		}
+		f.Since = t
+	}
+
+	var out io.Writer = os.Stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	count := 0
+	err := db.ForEachAuditEvent(f, func(e *models.AuditEvent) error {
+		count++
+		return enc.Encode(e)
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if path != "-" {
+		log.Printf("✅ Exported %d audit events to %s", count, path)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"devops-manual/internal/backup"
+	"devops-manual/internal/blobstore"
+	"devops-manual/internal/database"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// runBackup выгружает темы, лабы, ревизии, вложения, теги и пользователей в архив path
+func runBackup(db *database.DB, blobs blobstore.BlobStore, path string) error {
+	s, err := db.DumpBackup()
+	if err != nil {
+		return err
+	}
+	if len(s.Attachments) > 0 && blobs == nil {
+		return fmt.Errorf("%d attachments to back up, but blob storage is not configured", len(s.Attachments))
+	}
+
+	// Пишем во временный файл рядом, чтобы оборванная выгрузка не затёрла прошлый архив
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	m, err := backup.Write(f, s, func(key string) (io.ReadCloser, error) {
+		return blobs.Get(context.Background(), key)
+	})
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	log.Printf("✅ Backup written to %s: %d topics, %d labs, %d revisions, %d attachments, %d tags, %d users",
+		path, m.Counts["topics"], m.Counts["labs"], m.Counts["revisions"], m.Counts["attachments"], m.Counts["tags"], m.Counts["users"])
+	return nil
+}
+
+// runRestore проверяет архив и вливает его в базу; dryRun только печатает итог.
+// Содержимое вложений записывается в хранилище до транзакции: если она
+// откатится, лишние блобы уберёт очистка неиспользуемых.
+func runRestore(db *database.DB, blobs blobstore.BlobStore, path, mode string, dryRun bool) error {
+	if !contains(backup.Modes, mode) {
+		return fmt.Errorf("restore mode must be one of %v", backup.Modes)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s, m, err := backup.Read(f)
+	if err != nil {
+		return err
+	}
+	log.Printf("Archive %s: schema version %d, created %s", path, m.SchemaVersion, m.CreatedAt.Format("2006-01-02 15:04:05"))
+
+	if len(s.Blobs) > 0 && blobs == nil {
+		return fmt.Errorf("archive has %d attachment blobs, but blob storage is not configured", len(s.Blobs))
+	}
+	if !dryRun {
+		// Проверяем лабы до загрузки вложений, чтобы не оставлять ненужные блобы
+		if invalid := s.ValidateLabs(); len(invalid) > 0 {
+			return backup.InvalidLabsError(invalid)
+		}
+		for key, data := range s.Blobs {
+			if err := blobs.Put(context.Background(), key, bytes.NewReader(data), int64(len(data))); err != nil {
+				return err
+			}
+		}
+	}
+
+	stats, err := db.RestoreBackup(s, mode, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		log.Printf("Dry run (%s), nothing changed. Would apply (+created ~updated -deleted):\n%s", mode, stats)
+	} else {
+		log.Printf("✅ Restored (%s) (+created ~updated -deleted):\n%s", mode, stats)
+	}
+	return nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
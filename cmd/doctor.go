@@ -0,0 +1,64 @@
+package main
+
+import (
+	"devops-manual/internal/config"
+	"devops-manual/internal/database"
+	"errors"
+	"fmt"
+)
+
+// runDoctor печатает все найденные проблемы настроек и безопасности: ошибки
+// конфигурации, небезопасные значения и админов с паролем по умолчанию.
+// Возвращает false, если проблемы есть.
+func runDoctor(cfg *config.Config, cfgErr error) bool {
+	var problems []error
+	var invalid config.Errors
+	if errors.As(cfgErr, &invalid) {
+		problems = append(problems, invalid...)
+	} else if cfgErr != nil {
+		problems = append(problems, cfgErr)
+	}
+	problems = append(problems, cfg.SecurityProblems()...)
+	problems = append(problems, checkDatabase(cfg, cfgErr)...)
+
+	mode := "development"
+	if cfg.Server.Production {
+		mode = "production"
+	}
+	fmt.Printf("Mode: %s\n\n", mode)
+	for _, p := range problems {
+		fmt.Println("❌", p)
+	}
+	if len(problems) == 0 {
+		fmt.Println("✅ No problems found")
+		return true
+	}
+	fmt.Printf("\nProblems found: %d\n", len(problems))
+	return false
+}
+
+// checkDatabase подключается к базе и ищет админов с паролем по умолчанию
+func checkDatabase(cfg *config.Config, cfgErr error) []error {
+	if cfgErr != nil {
+		return []error{errors.New("database: not checked until the configuration is valid")}
+	}
+	db, err := database.New(cfg.Database)
+	if err != nil {
+		return []error{fmt.Errorf("database: %v", err)}
+	}
+	defer db.Close()
+	return defaultPasswordAdmins(db)
+}
+
+// defaultPasswordAdmins - проблема на каждого админа с паролем из config.DefaultPasswords
+func defaultPasswordAdmins(db *database.DB) []error {
+	admins, err := db.GetAdminsWithPassword(config.DefaultPasswords)
+	if err != nil {
+		return []error{fmt.Errorf("database: %v", err)}
+	}
+	var problems []error
+	for _, name := range admins {
+		problems = append(problems, fmt.Errorf("user %s: admin password is a known default; change it", name))
+	}
+	return problems
+}
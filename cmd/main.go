@@ -1,12 +1,19 @@
 package main
 
 import (
+	"devops-manual/internal/blobstore"
+	"devops-manual/internal/config"
 	"devops-manual/internal/database"
 	"devops-manual/internal/handlers"
+	"devops-manual/internal/markdown"
+	"devops-manual/internal/models"
 	"flag"
+	"fmt"
 	"html/template"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -15,12 +22,63 @@ import (
 func main() {
 	// Флаг для создания админа
 	createAdmin := flag.Bool("create-admin", false, "Create admin user")
+	exportAudit := flag.String("export-audit", "", "Export audit log as JSON Lines to file (- for stdout)")
+	exportStatic := flag.String("export-static", "", "Render published topics and labs to static HTML in directory")
+	auditSince := flag.String("audit-since", "", "Export only audit events since date (YYYY-MM-DD)")
+	backupPath := flag.String("backup", "", "Write topics, labs, revisions, attachments, tags and users to tar.gz archive")
+	restorePath := flag.String("restore", "", "Restore content from tar.gz archive made by -backup")
+	restoreMode := flag.String("restore-mode", "merge", "Restore mode: merge (add and update) or replace (match archive)")
+	dryRun := flag.Bool("dry-run", false, "With -restore: validate archive and report changes without applying them")
+	configPath := flag.String("config", "", "Config file (.yaml, .yml or .toml); default $CONFIG_FILE")
+	var overrides config.Overrides
+	flag.Var(&overrides, "set", "Override config value, e.g. -set database.host=db (repeatable)")
+	printConfig := flag.Bool("print-config", false, "Print effective config with secrets redacted and exit")
+	doctor := flag.Bool("doctor", false, "List configuration and security problems and exit")
 	flag.Parse()
 
 	godotenv.Load()
 
+	// Настройки: файл, окружение, -set
+	if *configPath == "" {
+		*configPath = os.Getenv("CONFIG_FILE")
+	}
+	cfg, err := config.Load(*configPath, overrides)
+	if *printConfig {
+		out, yamlErr := cfg.YAML()
+		if yamlErr != nil {
+			log.Fatal(yamlErr)
+		}
+		os.Stdout.Write(out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *doctor {
+		if !runDoctor(cfg, err) {
+			os.Exit(1)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *createAdmin {
+		if errs := cfg.ValidateAdmin(); len(errs) > 0 {
+			log.Fatal(config.Errors(errs))
+		}
+	}
+
+	// В production небезопасные настройки - ошибка, иначе только напоминание
+	if problems := cfg.SecurityProblems(); len(problems) > 0 {
+		if cfg.Server.Production {
+			log.Fatal("Refusing to start in production mode: ", config.Errors(problems))
+		}
+		log.Printf("⚠️ %d security problems in configuration, run with -doctor for details", len(problems))
+	}
+
 	// Подключение к БД
-	db, err := database.New()
+	db, err := database.New(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -33,56 +91,164 @@ func main() {
 
 	// Создание админа и выход
 	if *createAdmin {
-		password := os.Getenv("ADMIN_PASSWORD")
-		if password == "" {
-			password = "admin123"
-		}
-		if err := db.CreateUser("admin", password, true); err != nil {
-			log.Println("Admin creation error (may already exist):", err)
+		err := db.CreateUser("admin", cfg.Admin.Password, true)
+		if database.IsUniqueViolation(err) {
+			// Админ уже есть, например с заглушкой вместо хеша из InitSchema
+			admin, err := db.GetUserByUsername("admin")
+			if err == nil {
+				err = db.SetPassword(admin.ID, cfg.Admin.Password)
+			}
+			if err != nil {
+				log.Fatal("Admin password reset failed:", err)
+			}
+			log.Println("✅ Admin password updated")
+			db.AddAuditEvent(&models.AuditEvent{
+				ActorName:  "cli",
+				Action:     "user.password_reset",
+				TargetType: "user",
+				TargetID:   strconv.Itoa(admin.ID),
+			})
+		} else if err != nil {
+			log.Println("Admin creation error:", err)
 		} else {
 			log.Println("✅ Admin created successfully")
+			if admin, err := db.GetUserByUsername("admin"); err == nil {
+				db.AddAuditEvent(&models.AuditEvent{
+					ActorName:  "cli",
+					Action:     "user.create",
+					TargetType: "user",
+					TargetID:   strconv.Itoa(admin.ID),
+				})
+			}
 		}
 		return
 	}
 
-	// Настройка Gin
-	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
-	
-	// Загрузка шаблонов с правильными именами
-	tmpl := template.New("")
-	
-	files := map[string]string{
-		"index.html":       "web/templates/index.html",
-		"topic/topic.html": "web/templates/topic/topic.html",
-		"lab/lab.html":     "web/templates/lab/lab.html",
-		"auth/login.html":  "web/templates/auth/login.html",
+	// Выгрузка журнала аудита и выход
+	if *exportAudit != "" {
+		if err := runExportAudit(db, *exportAudit, *auditSince); err != nil {
+			log.Fatal("Audit export failed:", err)
+		}
+		return
 	}
-	
-	for name, path := range files {
-		content, err := os.ReadFile(path)
+
+	// Статическая версия сайта и выход
+	if *exportStatic != "" {
+		tmpl, err := loadTemplates()
+		if err != nil {
+			log.Fatal(err)
+		}
+		blobs, err := blobstore.NewFromConfig(cfg.Attachments)
+		if err != nil {
+			log.Println("Attachments will not be exported:", err)
+		}
+		if err := runExportStatic(db, tmpl, blobs, *exportStatic); err != nil {
+			log.Fatal("Static export failed:", err)
+		}
+		return
+	}
+
+	// Резервная копия содержимого и выход
+	if *backupPath != "" {
+		blobs, err := blobstore.NewFromConfig(cfg.Attachments)
 		if err != nil {
-			log.Fatal("Failed to read template", name, ":", err)
+			log.Println("Blob storage is not available:", err)
+		}
+		if err := runBackup(db, blobs, *backupPath); err != nil {
+			log.Fatal("Backup failed:", err)
 		}
-		tmpl, err = tmpl.New(name).Parse(string(content))
+		return
+	}
+
+	// Восстановление из резервной копии и выход
+	if *restorePath != "" {
+		blobs, err := blobstore.NewFromConfig(cfg.Attachments)
 		if err != nil {
-			log.Fatal("Failed to parse template", name, ":", err)
+			log.Println("Blob storage is not available:", err)
+		}
+		if err := runRestore(db, blobs, *restorePath, *restoreMode, *dryRun); err != nil {
+			log.Fatal("Restore failed:", err)
 		}
+		return
+	}
+
+	// Учётки создаются и меняются командами выше, поэтому проверяем перед запуском сервера
+	if cfg.Server.Production {
+		if problems := defaultPasswordAdmins(db); len(problems) > 0 {
+			log.Fatal("Refusing to start in production mode: ", config.Errors(problems))
+		}
+	}
+
+	// Автоочистка корзины: trash.retention_days=0 отключает
+	if days := cfg.Trash.RetentionDays; days > 0 {
+		db.StartTrashPurge(time.Duration(days)*24*time.Hour, time.Hour)
+	}
+
+	// Настройка Gin
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.Default()
+	
+	// Загрузка шаблонов с правильными именами
+	tmpl, err := loadTemplates()
+	if err != nil {
+		log.Fatal(err)
 	}
 	
 	r.SetHTMLTemplate(tmpl)
 
 	// Инициализация обработчиков
-	h := handlers.New(db)
+	h := handlers.New(db, cfg)
 	h.RegisterRoutes(r)
 
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	// Плановая публикация лаб
+	h.StartScheduler(time.Minute)
+
+	// Уборка блобов, на которые не ссылается ни одно вложение
+	if h.Blobs != nil {
+		h.StartBlobCleanup(time.Hour, time.Hour)
+	}
+
+	// Песочница: закрытие простаивающих терминалов и уборка контейнеров
+	if h.Sandbox != nil {
+		h.Sandbox.Start(time.Minute)
+
+		// Ночная проверка лаб: sandbox.verify_hour - час запуска, -1 отключает
+		if hour := cfg.Sandbox.VerifyHour; hour >= 0 {
+			h.StartVerifier(hour, 15*time.Minute)
+		}
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
+	log.Printf("Server starting on port %d", cfg.Server.Port)
+	if err := r.Run(":" + strconv.Itoa(cfg.Server.Port)); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+// loadTemplates загружает шаблоны под именами, по которым их вызывают обработчики
+func loadTemplates() (*template.Template, error) {
+	tmpl := template.New("").Funcs(template.FuncMap{
+		"add":      func(a, b int) int { return a + b },
+		"markdown": func(s string) template.HTML { return template.HTML(markdown.Render(s)) },
+	})
+
+	files := map[string]string{
+		"index.html":                 "web/templates/index.html",
+		"topic/topic.html":           "web/templates/topic/topic.html",
+		"lab/lab.html":               "web/templates/lab/lab.html",
+		"auth/login.html":            "web/templates/auth/login.html",
+		"auth/register.html":         "web/templates/auth/register.html",
+		"path/path.html":             "web/templates/path/path.html",
+		"collection/collection.html": "web/templates/collection/collection.html",
+	}
+
+	for name, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+		if _, err := tmpl.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"devops-manual/internal/blobstore"
+	"devops-manual/internal/database"
+	"devops-manual/internal/labvars"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchDoc - запись клиентского поискового индекса статической версии
+type searchDoc struct {
+	Title string   `json:"title"`
+	Topic string   `json:"topic"`
+	URL   string   `json:"url"`
+	Tags  []string `json:"tags"`
+	Text  string   `json:"text"`
+}
+
+// runExportStatic рендерит главную, темы и опубликованные лабы в dir через те же
+// шаблоны с флагом static (без входа, админки и вызовов API), копирует web/static
+// и пишет search-index.json. Ссылки делаются относительными, чтобы сайт открывался
+// с любого пути и прямо с диска. Вложения лаб копируются из blobs, если хранилище
+// настроено (nil - без вложений).
+func runExportStatic(db *database.DB, tmpl *template.Template, blobs blobstore.BlobStore, dir string) error {
+	topics, err := db.GetTopics()
+	if err != nil {
+		return err
+	}
+	topLabs, err := db.GetTopRatedLabs(time.Now().AddDate(0, 0, -30), 6)
+	if err != nil {
+		return err
+	}
+
+	err = renderStatic(tmpl, dir, "index.html", "index.html", map[string]interface{}{
+		"title":    "DevOps Manual",
+		"topics":   topics,
+		"top_labs": topLabs,
+		"static":   true,
+	})
+	if err != nil {
+		return err
+	}
+
+	index := []searchDoc{}
+	labCount := 0
+	for i := range topics {
+		topic := &topics[i]
+		labs, err := db.GetLabsByTopicSlug(topic.Slug, true)
+		if err != nil {
+			return err
+		}
+
+		err = renderStatic(tmpl, dir, "topic/"+topic.Slug+".html", "topic/topic.html", map[string]interface{}{
+			"title":  topic.Title,
+			"topic":  topic,
+			"labs":   labs,
+			"total":  len(labs),
+			"static": true,
+		})
+		if err != nil {
+			return err
+		}
+
+		for j := range labs {
+			lab := &labs[j]
+			view := labvars.Defaults(lab)
+			values, _ := labvars.Values(lab.Variables, nil)
+			page := "lab/" + topic.Slug + "/" + lab.Slug + ".html"
+			err = renderStatic(tmpl, dir, page, "lab/lab.html", map[string]interface{}{
+				"title":   lab.Title,
+				"lab":     lab,
+				"view":    view,
+				"content": template.HTML(labvars.RenderContent(lab, values)),
+				"static":  true,
+			})
+			if err != nil {
+				return err
+			}
+
+			if blobs != nil {
+				if err := copyAttachments(db, blobs, lab.ID, dir); err != nil {
+					return err
+				}
+			}
+
+			index = append(index, searchDoc{
+				Title: lab.Title,
+				Topic: topic.Title,
+				URL:   page,
+				Tags:  lab.Tags,
+				Text:  view.Content + "\n" + strings.Join(view.Commands, "\n"),
+			})
+			labCount++
+		}
+	}
+
+	if err := writeSearchIndex(filepath.Join(dir, "search-index.json"), index); err != nil {
+		return err
+	}
+	if err := copyDir("web/static", filepath.Join(dir, "static")); err != nil {
+		return err
+	}
+
+	log.Printf("✅ Exported %d topics and %d labs to %s", len(topics), labCount, dir)
+	return nil
+}
+
+// renderStatic выполняет шаблон и сохраняет страницу page (путь внутри dir)
+func renderStatic(tmpl *template.Template, dir, page, name string, data map[string]interface{}) error {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(page))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	root := strings.Repeat("../", strings.Count(page, "/"))
+	return os.WriteFile(path, []byte(relativeLinks(buf.String(), root)), 0644)
+}
+
+var siteLinkRe = regexp.MustCompile(`(href|src)="/([^"]*)"`)
+
+// relativeLinks переписывает ссылки вида /topic/x и /lab/t/l на файлы статической
+// версии относительно root. Ссылки на разделы, которых в ней нет (маршруты, вход),
+// заменяются на "#".
+func relativeLinks(html, root string) string {
+	return siteLinkRe.ReplaceAllStringFunc(html, func(m string) string {
+		parts := siteLinkRe.FindStringSubmatch(m)
+		attr, target := parts[1], parts[2]
+
+		fragment := ""
+		if i := strings.Index(target, "#"); i >= 0 {
+			target, fragment = target[:i], target[i:]
+		}
+		if i := strings.Index(target, "?"); i >= 0 {
+			target = target[:i]
+		}
+
+		segments := strings.Split(strings.Trim(target, "/"), "/")
+		switch {
+		case target == "":
+			target = "index.html"
+		case segments[0] == "topic" && len(segments) == 2:
+			target = target + ".html"
+		case segments[0] == "lab" && len(segments) == 3:
+			target = target + ".html"
+		case segments[0] == "static":
+		case segments[0] == "attachments" && len(segments) == 3:
+		default:
+			return attr + `="#"`
+		}
+		return attr + `="` + root + target + fragment + `"`
+	})
+}
+
+// copyAttachments сохраняет вложения лабы как attachments/<id>/<имя>, по тем же
+// путям, что и ссылки на них в тексте
+func copyAttachments(db *database.DB, blobs blobstore.BlobStore, labID int, dir string) error {
+	attachments, err := db.GetAttachments(labID)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		path := filepath.Join(dir, "attachments", strconv.Itoa(a.ID), filepath.Base(a.Filename))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		blob, err := blobs.Get(context.Background(), a.BlobKey)
+		if err != nil {
+			return fmt.Errorf("attachment %d: %w", a.ID, err)
+		}
+		out, err := os.Create(path)
+		if err == nil {
+			_, err = io.Copy(out, blob)
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+		}
+		blob.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSearchIndex(path string, docs []searchDoc) error {
+	b, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// copyDir копирует каталог со статикой; отсутствующий каталог - не ошибка
+func copyDir(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -1,65 +0,0 @@
-package main
-
-import (
-	"database/sql"
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
-	"golang.org/x/crypto/bcrypt"
-)
-
-func main() {
-	godotenv.Load()
-	
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	)
-	
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
-	
-	// Создаём таблицу users
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			username VARCHAR(255) UNIQUE NOT NULL,
-			password_hash VARCHAR(255) NOT NULL,
-			is_admin BOOLEAN DEFAULT false,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		log.Fatal("Create table error:", err)
-	}
-	
-	password := os.Getenv("ADMIN_PASSWORD")
-	if password == "" {
-		password = "admin123"
-	}
-	
-	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	
-	_, err = db.Exec(`
-		INSERT INTO users (username, password_hash, is_admin) 
-		VALUES ('admin', $1, true) 
-		ON CONFLICT (username) DO UPDATE SET password_hash = $1`,
-		string(hash))
-	
-	if err != nil {
-		log.Fatal("Insert error:", err)
-	}
-	
-	fmt.Println("✅ Admin создан/обновлён")
-	fmt.Println("Логин: admin")
-	fmt.Println("Пароль:", password)
-}
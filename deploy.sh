@@ -74,6 +74,8 @@ DB_PORT=5432
 DB_USER=${DB_USER}
 DB_PASSWORD=${DB_PASSWORD}
 DB_NAME=${DB_NAME}
+# База на этом же сервере, соединение не выходит за localhost
+DB_SSLMODE=disable
 
 # Telegram Bot
 TELEGRAM_BOT_TOKEN=${TELEGRAM_TOKEN}
@@ -91,40 +93,11 @@ echo -e "${YELLOW}🔨 Сборка приложения...${NC}"
 cd ${PROJECT_DIR}
 export PATH=$PATH:/usr/local/go/bin
 go mod tidy
-go build -o ${PROJECT_NAME} cmd/main.go
+go build -o ${PROJECT_NAME} ./cmd
 
 echo -e "${YELLOW}👤 Создание администратора...${NC}"
-cat > /tmp/create_admin.go << 'GOADMIN'
-package main
-import (
-    "database/sql"
-    "fmt"
-    "os"
-    "github.com/joho/godotenv"
-    _ "github.com/lib/pq"
-    "golang.org/x/crypto/bcrypt"
-)
-func main() {
-    godotenv.Load()
-    connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-        os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"),
-        os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
-    db, err := sql.Open("postgres", connStr)
-    if err != nil { panic(err) }
-    defer db.Close()
-    
-    password := os.Getenv("ADMIN_PASSWORD")
-    hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-    
-    _, err = db.Exec("INSERT INTO users (username, password_hash, is_admin) VALUES ('admin', $1, true) ON CONFLICT (username) DO UPDATE SET password_hash = $1", string(hash))
-    if err != nil { panic(err) }
-    fmt.Println("✅ Admin создан")
-}
-GOADMIN
-
-cd /tmp && go mod init tmpadmin && go get github.com/joho/godotenv github.com/lib/pq golang.org/x/crypto/bcrypt
-cp ${PROJECT_DIR}/.env /tmp/.env
-go run create_admin.go
+# Те же проверки настроек, что и при запуске сервера; пароль берётся из ADMIN_PASSWORD в .env
+./${PROJECT_NAME} -create-admin
 
 echo -e "${YELLOW}🔧 Настройка Nginx...${NC}"
 cat > /etc/nginx/sites-available/${PROJECT_NAME} << NGINX
@@ -0,0 +1,218 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	manifestName = "manifest.json"
+	blobPrefix   = "blobs/"
+)
+
+// entry - файл архива и соответствующее ему поле снимка; since - версия схемы,
+// с которой файл появился (в более старых архивах его нет)
+type entry struct {
+	name  string
+	data  interface{}
+	since int
+}
+
+func (s *Snapshot) entries() []entry {
+	return []entry{
+		{"topics.json", &s.Topics, 1},
+		{"tags.json", &s.Tags, 1},
+		{"users.json", &s.Users, 1},
+		{"labs.json", &s.Labs, 1},
+		{"revisions.json", &s.Revisions, 1},
+		{"attachments.json", &s.Attachments, 2},
+	}
+}
+
+// BlobOpener открывает содержимое вложения по ключу
+type BlobOpener func(key string) (io.ReadCloser, error)
+
+// Write пишет снимок в w как tar.gz. Содержимое вложений читается через open
+// по одному разу на ключ. manifest.json идёт последним, когда известны
+// контрольные суммы всех файлов.
+func Write(w io.Writer, s *Snapshot, open BlobOpener) (*Manifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now().UTC()
+
+	m := &Manifest{
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     now,
+		Counts: map[string]int{
+			"topics":      len(s.Topics),
+			"tags":        len(s.Tags),
+			"users":       len(s.Users),
+			"labs":        len(s.Labs),
+			"revisions":   len(s.Revisions),
+			"attachments": len(s.Attachments),
+		},
+	}
+
+	for _, e := range s.entries() {
+		b, err := json.MarshalIndent(e.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(tw, e.name, bytes.NewReader(b), int64(len(b)), now); err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, File{Name: e.name, Size: int64(len(b)), SHA256: Checksum(b)})
+	}
+
+	written := make(map[string]bool)
+	for _, a := range s.Attachments {
+		if written[a.BlobKey] {
+			continue
+		}
+		written[a.BlobKey] = true
+
+		blob, err := open(a.BlobKey)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %s of %s: %w", a.Filename, a.Lab, err)
+		}
+		// Ключ - хеш содержимого: сверяем, чтобы не сохранить повреждённый блоб
+		h := sha256.New()
+		err = writeFile(tw, blobPrefix+a.BlobKey, io.TeeReader(blob, h), a.Size, now)
+		blob.Close()
+		if err != nil {
+			return nil, fmt.Errorf("attachment %s of %s: %w", a.Filename, a.Lab, err)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != a.BlobKey {
+			return nil, fmt.Errorf("attachment %s of %s: stored blob is corrupted", a.Filename, a.Lab)
+		}
+		m.Files = append(m.Files, File{Name: blobPrefix + a.BlobKey, Size: a.Size, SHA256: a.BlobKey})
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(tw, manifestName, bytes.NewReader(b), int64(len(b)), now); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gz.Close()
+}
+
+// writeFile пишет файл размером size; tar сам вернёт ошибку, если r короче или длиннее
+func writeFile(tw *tar.Writer, name string, r io.Reader, size int64, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime, Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(tw, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("%s: expected %d bytes, got %d", name, size, n)
+	}
+	return err
+}
+
+// Checksum - SHA-256 содержимого в hex
+func Checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Read читает архив, проверяет версию схемы, состав файлов и контрольные суммы.
+// Ничего не возвращает, пока архив не прошёл все проверки.
+func Read(r io.Reader) (*Snapshot, *Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		files[hdr.Name] = buf.Bytes()
+	}
+
+	raw, ok := files[manifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s not found", ErrCorrupted, manifestName)
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrCorrupted, manifestName, err)
+	}
+	if m.SchemaVersion < MinSchemaVersion || m.SchemaVersion > SchemaVersion {
+		return nil, nil, fmt.Errorf("%w: archive has version %d, supported %d..%d",
+			ErrIncompatible, m.SchemaVersion, MinSchemaVersion, SchemaVersion)
+	}
+
+	listed := map[string]bool{manifestName: true}
+	for _, f := range m.Files {
+		listed[f.Name] = true
+		b, ok := files[f.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s listed in manifest but missing", ErrCorrupted, f.Name)
+		}
+		if int64(len(b)) != f.Size || Checksum(b) != f.SHA256 {
+			return nil, nil, fmt.Errorf("%w: checksum mismatch for %s", ErrCorrupted, f.Name)
+		}
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, nil, fmt.Errorf("%w: %s is not listed in manifest", ErrCorrupted, name)
+		}
+	}
+
+	s := &Snapshot{Blobs: make(map[string][]byte)}
+	for _, e := range s.entries() {
+		b, ok := files[e.name]
+		if !ok {
+			if m.SchemaVersion < e.since {
+				continue
+			}
+			return nil, nil, fmt.Errorf("%w: %s not found", ErrCorrupted, e.name)
+		}
+		if err := json.Unmarshal(b, e.data); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrCorrupted, e.name, err)
+		}
+	}
+
+	for name, b := range files {
+		if key := strings.TrimPrefix(name, blobPrefix); key != name {
+			if Checksum(b) != key {
+				return nil, nil, fmt.Errorf("%w: blob %s does not match its key", ErrCorrupted, key)
+			}
+			s.Blobs[key] = b
+		}
+	}
+	for _, a := range s.Attachments {
+		if _, ok := s.Blobs[a.BlobKey]; !ok {
+			return nil, nil, fmt.Errorf("%w: content of attachment %s of %s is missing", ErrCorrupted, a.Filename, a.Lab)
+		}
+	}
+	return s, &m, nil
+}
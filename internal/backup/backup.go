@@ -0,0 +1,229 @@
+// Package backup описывает архив с содержимым справочника: темы, лабы, их
+// ревизии и вложения, теги и пользователи (только хеши паролей). Сессии, логи,
+// прогресс и комментарии в архив не попадают - для них остаётся pg_dump.
+//
+// Архив - tar.gz с manifest.json, JSON файлом на каждую сущность и содержимым
+// вложений в blobs/<sha256>. Записи ссылаются друг на друга по естественным
+// ключам (slug, username), а не по id, поэтому архив можно влить в базу с другим
+// набором данных.
+package backup
+
+import (
+	"devops-manual/internal/labcheck"
+	"devops-manual/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchemaVersion - версия формата архива; повышается при несовместимых изменениях
+// записей. MinSchemaVersion - самая старая версия, которую умеет читать restore.
+//
+// Версия 2 добавила вложения (attachments.json и blobs/).
+const (
+	SchemaVersion    = 2
+	MinSchemaVersion = 1
+)
+
+// Режимы восстановления
+const (
+	// ModeMerge добавляет и обновляет записи из архива, остальное не трогает
+	ModeMerge = "merge"
+	// ModeReplace приводит содержимое к архиву: лишние лабы уходят в корзину,
+	// лишние теги и пустые темы удаляются. Пользователи не удаляются никогда.
+	ModeReplace = "replace"
+)
+
+// Modes - поддерживаемые режимы восстановления
+var Modes = []string{ModeMerge, ModeReplace}
+
+var (
+	// ErrIncompatible - версия архива не поддерживается этой сборкой
+	ErrIncompatible = errors.New("incompatible backup schema version")
+	// ErrCorrupted - архив повреждён: нет файла, не сходится размер или контрольная сумма
+	ErrCorrupted = errors.New("corrupted backup")
+)
+
+// Manifest - оглавление архива
+type Manifest struct {
+	SchemaVersion int            `json:"schema_version"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Counts        map[string]int `json:"counts"`
+	Files         []File         `json:"files"`
+}
+
+// File - файл архива с размером и SHA-256 содержимого
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Snapshot - содержимое справочника на момент выгрузки
+type Snapshot struct {
+	Topics      []Topic
+	Tags        []Tag
+	Users       []User
+	Labs        []Lab
+	Revisions   []Revision
+	Attachments []Attachment
+
+	// Blobs - содержимое вложений по ключу, заполняется при чтении архива
+	Blobs map[string][]byte
+}
+
+type Topic struct {
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Tag struct {
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// User - учётная запись; пароль только в виде bcrypt хеша
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	IsAdmin      bool      `json:"is_admin"`
+	Role         string    `json:"role"`
+	OIDCSubject  string    `json:"oidc_subject,omitempty"`
+	Email        string    `json:"email,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Lab - лаба вместе с тегами и пререквизитами; удалённые в корзину не выгружаются
+type Lab struct {
+	Topic       string     `json:"topic"`
+	Slug        string     `json:"slug"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Commands    []string   `json:"commands"`
+	Difficulty  string     `json:"difficulty"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	Tags        []string   `json:"tags"`
+	// Пререквизиты в виде "topic/lab"
+	Requires         []string        `json:"requires"`
+	Expectations     json.RawMessage `json:"expectations,omitempty"`
+	Variables        json.RawMessage `json:"variables,omitempty"`
+	DestructiveSteps []int           `json:"destructive_steps,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// Key - естественный ключ лабы "topic/lab"
+func (l *Lab) Key() string {
+	return l.Topic + "/" + l.Slug
+}
+
+// Revision - событие lab.create или lab.update журнала аудита со снимками лабы
+type Revision struct {
+	Lab       string          `json:"lab"` // "topic/lab"
+	Action    string          `json:"action"`
+	ActorName string          `json:"actor_name"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Attachment - вложение лабы. ID - прежний id, по нему при восстановлении
+// переписываются ссылки /attachments/<id>/ в тексте лаб.
+type Attachment struct {
+	ID          int       `json:"id"`
+	Lab         string    `json:"lab"` // "topic/lab"
+	BlobKey     string    `json:"blob_key"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Change - сколько записей одного вида создано, обновлено и удалено
+type Change struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// Stats - итог восстановления
+type Stats struct {
+	Topics      Change `json:"topics"`
+	Tags        Change `json:"tags"`
+	Users       Change `json:"users"`
+	Labs        Change `json:"labs"`
+	Revisions   Change `json:"revisions"`
+	Attachments Change `json:"attachments"`
+	// Invalid - лабы, не прошедшие проверку; заполняется только в пробном прогоне,
+	// настоящее восстановление с ними не выполняется
+	Invalid []InvalidLab `json:"invalid,omitempty"`
+}
+
+func (s *Stats) String() string {
+	line := func(name string, c Change) string {
+		return fmt.Sprintf("%-12s +%d ~%d -%d\n", name, c.Created, c.Updated, c.Deleted)
+	}
+	out := line("topics", s.Topics) + line("tags", s.Tags) + line("users", s.Users) +
+		line("labs", s.Labs) + line("revisions", s.Revisions) + line("attachments", s.Attachments)
+	if len(s.Invalid) > 0 {
+		out += InvalidLabsError(s.Invalid).Error() + "\n"
+	}
+	return out
+}
+
+// InvalidLab - лаба архива, которую API не дал бы сохранить
+type InvalidLab struct {
+	Lab   string `json:"lab"` // "topic/lab"
+	Error string `json:"error"`
+}
+
+// InvalidLabsError - архив содержит лабы, не прошедшие проверку
+type InvalidLabsError []InvalidLab
+
+func (e InvalidLabsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d invalid labs:", len(e))
+	for _, l := range e {
+		fmt.Fprintf(&b, "\n  %s: %s", l.Lab, strings.ReplaceAll(l.Error, "\n", "; "))
+	}
+	return b.String()
+}
+
+// ValidateLabs проверяет лабы архива теми же правилами, что и API
+// (ожидания шагов, необратимые шаги, переменные)
+func (s *Snapshot) ValidateLabs() []InvalidLab {
+	var invalid []InvalidLab
+	for i := range s.Labs {
+		l := &s.Labs[i]
+		lab := &models.Lab{Content: l.Content, Commands: l.Commands, DestructiveSteps: l.DestructiveSteps}
+		err := unmarshalOptional(l.Expectations, &lab.Expected, "expectations")
+		if err == nil {
+			err = unmarshalOptional(l.Variables, &lab.Variables, "variables")
+		}
+		if err == nil {
+			err = labcheck.Validate(lab)
+		}
+		if err != nil {
+			invalid = append(invalid, InvalidLab{Lab: l.Key(), Error: err.Error()})
+		}
+	}
+	return invalid
+}
+
+func unmarshalOptional(raw json.RawMessage, v interface{}, field string) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%s: %v", field, err)
+	}
+	return nil
+}
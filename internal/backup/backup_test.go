@@ -0,0 +1,45 @@
+package backup
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateLabs(t *testing.T) {
+	s := &Snapshot{Labs: []Lab{
+		{Topic: "docker", Slug: "basics", Commands: []string{"docker ps"},
+			Expectations: json.RawMessage(`[{"output":"/CONTAINER ID/"}]`)},
+		{Topic: "docker", Slug: "volumes", Commands: []string{"docker volume ls"}, Expectations: json.RawMessage(`null`)},
+		{Topic: "k8s", Slug: "regexp", Commands: []string{"kubectl get pods"},
+			Expectations: json.RawMessage(`[{"output":"/([a-z/"}]`)},
+		{Topic: "k8s", Slug: "steps", Commands: []string{"kubectl delete ns demo"}, DestructiveSteps: []int{1}},
+		{Topic: "k8s", Slug: "vars", Commands: []string{"kubectl -n {{ .namespace }} get pods"}},
+		{Topic: "k8s", Slug: "broken", Variables: json.RawMessage(`{"name":"ns"}`)},
+	}}
+
+	invalid := s.ValidateLabs()
+	want := map[string]string{
+		"k8s/regexp": "expected[0].output",
+		"k8s/steps":  "destructive_steps: step 1 out of range",
+		"k8s/vars":   "undeclared variables: namespace",
+		"k8s/broken": "variables:",
+	}
+	if len(invalid) != len(want) {
+		t.Fatalf("ValidateLabs = %+v, want %d invalid labs", invalid, len(want))
+	}
+	for _, l := range invalid {
+		if !strings.Contains(l.Error, want[l.Lab]) {
+			t.Errorf("%s: error %q does not contain %q", l.Lab, l.Error, want[l.Lab])
+		}
+	}
+
+	msg := InvalidLabsError(invalid).Error()
+	if !strings.HasPrefix(msg, "4 invalid labs:") || !strings.Contains(msg, "\n  k8s/steps: ") {
+		t.Errorf("unexpected error message:\n%s", msg)
+	}
+	stats := Stats{Invalid: invalid}
+	if !strings.Contains(stats.String(), "k8s/vars") {
+		t.Errorf("dry run report does not list invalid labs:\n%s", stats.String())
+	}
+}
@@ -0,0 +1,86 @@
+// Package blobstore хранит содержимое вложений лаб. Ключ блоба - SHA-256 его
+// содержимого, поэтому одинаковые файлы хранятся один раз, а повторная запись
+// ничего не меняет. Метаданные (имя файла, тип, к какой лабе относится) живут
+// в базе, хранилище о них не знает.
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"devops-manual/internal/config"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+// ErrNotFound - блоба с таким ключом нет
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey - ключ не является SHA-256 в hex
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Blob - блоб в листинге хранилища
+type Blob struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore - хранилище блобов по ключу-хешу
+type BlobStore interface {
+	// Put сохраняет содержимое под ключом key; size - точный размер r.
+	// Если блоб уже есть, содержимое не перезаписывается, но время изменения
+	// обновляется: уборка не трогает свежие блобы, а строку вложения могли
+	// ещё не вставить.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get открывает блоб на чтение; ErrNotFound, если его нет
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete удаляет блоб; отсутствие блоба не ошибка
+	Delete(ctx context.Context, key string) error
+	// List перечисляет все блобы, нужен для уборки неиспользуемых
+	List(ctx context.Context) ([]Blob, error)
+}
+
+var keyRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidKey - ключ имеет вид SHA-256 в hex
+func ValidKey(key string) bool {
+	return keyRe.MatchString(key)
+}
+
+// Key возвращает ключ для содержимого
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewFromConfig создаёт хранилище по cfg.Store: fs (каталог cfg.Dir) или s3
+// (любой S3-совместимый сервис: AWS, MinIO, Ceph)
+func NewFromConfig(cfg config.Attachments) (BlobStore, error) {
+	// Конкретные типы не возвращаем напрямую: nil *FS в интерфейсе не равен nil
+	switch kind := cfg.Store; kind {
+	case "", "fs":
+		store, err := NewFS(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "s3":
+		store, err := NewS3(S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q (want fs or s3)", kind)
+	}
+}
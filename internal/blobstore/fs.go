@@ -0,0 +1,106 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FS хранит блобы в локальном каталоге: <dir>/<первые 2 символа ключа>/<ключ>
+type FS struct {
+	dir string
+}
+
+// NewFS создаёт хранилище в каталоге dir, создавая его при необходимости
+func NewFS(dir string) (*FS, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FS{dir: dir}, nil
+}
+
+func (s *FS) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+func (s *FS) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		return os.Chtimes(path, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Пишем во временный файл и переименовываем: читатели не увидят недописанный блоб
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n != size || hex.EncodeToString(h.Sum(nil)) != key {
+		return fmt.Errorf("blob %s: content does not match key or size", key)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *FS) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *FS) List(ctx context.Context) ([]Blob, error) {
+	blobs := []Blob{}
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Временные файлы и посторонние имена пропускаем
+		if d.IsDir() || !ValidKey(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, Blob{Key: d.Name(), Size: info.Size(), ModTime: info.ModTime()})
+		return ctx.Err()
+	})
+	return blobs, err
+}
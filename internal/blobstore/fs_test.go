@@ -0,0 +1,121 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFSRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	data := []byte("docker run --rm hello-world")
+	key := Key(data)
+
+	if err := store.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(got, data) {
+		t.Errorf("Get = %q, want %q", got, data)
+	}
+
+	// Временные и посторонние файлы в листинг не попадают
+	os.WriteFile(filepath.Join(dir, key[:2], key+".tmp-1"), []byte("partial"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+	blobs, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0].Key != key || blobs[0].Size != int64(len(data)) {
+		t.Errorf("List = %+v, want only %s", blobs, key)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of missing blob: %v", err)
+	}
+}
+
+func TestFSPutRejectsMismatch(t *testing.T) {
+	store, err := NewFS(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	data := []byte("kubectl apply -f .")
+
+	tests := []struct {
+		name string
+		key  string
+		size int64
+	}{
+		{"wrong key", Key([]byte("something else")), int64(len(data))},
+		{"wrong size", Key(data), int64(len(data)) + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Put(ctx, tt.key, bytes.NewReader(data), tt.size); err == nil {
+				t.Fatal("Put succeeded")
+			}
+			if _, err := store.Get(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("rejected blob is stored: err = %v", err)
+			}
+		})
+	}
+
+	if err := store.Put(ctx, "../../etc/passwd", bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put with invalid key: err = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestFSPutExistingRefreshesModTime(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	data := []byte("ansible-playbook site.yml")
+	key := Key(data)
+	if err := store.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	path := filepath.Join(dir, key[:2], key)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	// Содержимое не читается повторно: ридер с ошибкой не мешает
+	if err := store.Put(ctx, key, strings.NewReader(""), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("ModTime = %v, want refreshed", info.ModTime())
+	}
+}
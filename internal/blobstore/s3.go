@@ -0,0 +1,310 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyHash - SHA-256 пустого тела запроса
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config - параметры S3-совместимого хранилища
+type S3Config struct {
+	// Endpoint - адрес сервиса, например https://s3.eu-central-1.amazonaws.com
+	// или http://minio:9000. Пусто - AWS в регионе Region.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3 хранит блобы в бакете S3-совместимого сервиса. Запросы подписываются
+// AWS Signature V4, бакет адресуется в пути (path-style), как принято у MinIO
+// и локальных заглушек. Ключ блоба совпадает с SHA-256 тела, поэтому сервис
+// сам проверяет целостность загрузки по заголовку x-amz-content-sha256.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3 проверяет настройки и создаёт хранилище; сеть при этом не используется
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	var missing []string
+	if cfg.Bucket == "" {
+		missing = append(missing, "S3_BUCKET")
+	}
+	if cfg.AccessKey == "" {
+		missing = append(missing, "S3_ACCESS_KEY")
+	}
+	if cfg.SecretKey == "" {
+		missing = append(missing, "S3_SECRET_KEY")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("s3 blob store: %s not set", strings.Join(missing, ", "))
+	}
+
+	u, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("s3 blob store: invalid endpoint %q", cfg.Endpoint)
+	}
+	return &S3{cfg: cfg, endpoint: u, client: &http.Client{Timeout: 10 * time.Minute}}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	// Блоб уже загружен - содержимое то же самое, повторять не нужно
+	res, err := s.do(ctx, http.MethodHead, key, nil, nil, 0, emptyHash)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return s.touch(ctx, key)
+	}
+
+	res, err = s.do(ctx, http.MethodPut, key, nil, r, size, key)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res)
+	}
+	return nil
+}
+
+// touch обновляет LastModified копированием объекта в самого себя; S3
+// разрешает такую копию только с заменой метаданных
+func (s *S3) touch(ctx context.Context, key string) error {
+	header := http.Header{}
+	header.Set("x-amz-copy-source", "/"+s.cfg.Bucket+"/"+key)
+	header.Set("x-amz-metadata-directive", "REPLACE")
+	res, err := s.doHeader(ctx, http.MethodPut, key, nil, header, nil, 0, emptyHash)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	res, err := s.do(ctx, http.MethodGet, key, nil, nil, 0, emptyHash)
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	defer res.Body.Close()
+	return nil, s3Error(res)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	res, err := s.do(ctx, http.MethodDelete, key, nil, nil, 0, emptyHash)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s3Error(res)
+	}
+	return nil
+}
+
+// listResult - ответ ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context) ([]Blob, error) {
+	blobs := []Blob{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		res, err := s.do(ctx, http.MethodGet, "", query, nil, 0, emptyHash)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			err := s3Error(res)
+			res.Body.Close()
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range page.Contents {
+			if ValidKey(obj.Key) {
+				blobs = append(blobs, Blob{Key: obj.Key, Size: obj.Size, ModTime: obj.LastModified})
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return blobs, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// do выполняет подписанный запрос к объекту key (пустой key - к самому бакету)
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	return s.doHeader(ctx, method, key, query, nil, body, size, payloadHash)
+}
+
+// doHeader - do с дополнительными заголовками x-amz-*, они тоже подписываются
+func (s *S3) doHeader(ctx context.Context, method, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.cfg.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, payloadHash, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign добавляет заголовки AWS Signature V4
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery кодирует параметры по правилам SigV4: сортировка по имени, пробел как %20
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, v := range query[name] {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// s3Error читает из ответа код и текст ошибки S3
+func s3Error(res *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if xml.Unmarshal(b, &body) == nil && body.Code != "" {
+		return fmt.Errorf("s3: %s: %s: %s", res.Status, body.Code, body.Message)
+	}
+	return errors.New("s3: " + res.Status)
+}
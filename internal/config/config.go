@@ -0,0 +1,178 @@
+// Package config собирает настройки приложения в одну типизированную структуру.
+//
+// Источники по возрастанию приоритета:
+//
+//  1. значения по умолчанию (Default);
+//  2. файл YAML или TOML (-config или CONFIG_FILE), формат по расширению;
+//  3. переменные окружения (DB_HOST, SERVER_PORT, ...); пустые не учитываются.
+//     Для любой переменной можно задать X_FILE - путь к файлу со значением,
+//     так передаются Docker secrets;
+//  4. флаги -set ключ=значение, ключ - путь в файле (database.host).
+//
+// Load проверяет всё сразу и возвращает все найденные ошибки одним списком.
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Config - настройки приложения. Теги: yaml и toml - ключ в файле, env -
+// переменная окружения, secret - значение скрывается в -print-config.
+type Config struct {
+	Server       Server       `yaml:"server" toml:"server"`
+	Database     Database     `yaml:"database" toml:"database"`
+	Admin        Admin        `yaml:"admin" toml:"admin"`
+	Telegram     Telegram     `yaml:"telegram" toml:"telegram"`
+	OIDC         OIDC         `yaml:"oidc" toml:"oidc"`
+	Registration Registration `yaml:"registration" toml:"registration"`
+	Trash        Trash        `yaml:"trash" toml:"trash"`
+	Sandbox      Sandbox      `yaml:"sandbox" toml:"sandbox"`
+	Attachments  Attachments  `yaml:"attachments" toml:"attachments"`
+}
+
+type Server struct {
+	Port   int    `yaml:"port" toml:"port" env:"SERVER_PORT"`
+	Domain string `yaml:"domain" toml:"domain" env:"DOMAIN"`
+	// Production - не запускаться с небезопасными настройками (см. SecurityProblems)
+	Production bool `yaml:"production" toml:"production" env:"PRODUCTION"`
+	// SecureCookies - cookie только по HTTPS; включать, когда перед приложением TLS
+	SecureCookies bool `yaml:"secure_cookies" toml:"secure_cookies" env:"SECURE_COOKIES"`
+}
+
+type Database struct {
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER"`
+	Password string `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME"`
+	// SSLMode - режим TLS libpq: disable, require, verify-ca, verify-full и т.д.;
+	// пусто - по умолчанию драйвера (require)
+	SSLMode string `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE"`
+	// SSLRootCert - CA для проверки сертификата сервера (verify-ca, verify-full)
+	SSLRootCert string `yaml:"sslrootcert" toml:"sslrootcert" env:"DB_SSLROOTCERT"`
+}
+
+// Admin - учётка, которую создаёт -create-admin; пароль нужен только ему
+type Admin struct {
+	Password string `yaml:"password" toml:"password" env:"ADMIN_PASSWORD" secret:"true"`
+}
+
+// Telegram - алерты мониторинга; без токена и чата не отправляются
+type Telegram struct {
+	BotToken string `yaml:"bot_token" toml:"bot_token" env:"TELEGRAM_BOT_TOKEN" secret:"true"`
+	ChatID   string `yaml:"chat_id" toml:"chat_id" env:"TELEGRAM_CHAT_ID"`
+}
+
+// OIDC - вход через SSO; пустой Issuer выключает SSO
+type OIDC struct {
+	Issuer       string `yaml:"issuer" toml:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string `yaml:"client_id" toml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL  string `yaml:"redirect_url" toml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	// Scopes через пробел или запятую
+	Scopes      string `yaml:"scopes" toml:"scopes" env:"OIDC_SCOPES"`
+	GroupsClaim string `yaml:"groups_claim" toml:"groups_claim" env:"OIDC_GROUPS_CLAIM"`
+	// RoleMap - соответствие группа=роль через запятую
+	RoleMap string `yaml:"role_map" toml:"role_map" env:"OIDC_ROLE_MAP"`
+}
+
+type Registration struct {
+	// Mode - closed, open или invite
+	Mode string `yaml:"mode" toml:"mode" env:"REGISTRATION_MODE"`
+}
+
+type Trash struct {
+	// RetentionDays - через сколько дней лабы из корзины стираются, 0 - никогда
+	RetentionDays int `yaml:"retention_days" toml:"retention_days" env:"TRASH_RETENTION_DAYS"`
+}
+
+// Sandbox - терминал в контейнере; пустой Runner выключает песочницу
+type Sandbox struct {
+	// Runner - docker или fake
+	Runner       string  `yaml:"runner" toml:"runner" env:"SANDBOX_RUNNER"`
+	DockerSocket string  `yaml:"docker_socket" toml:"docker_socket" env:"SANDBOX_DOCKER_SOCKET"`
+	Image        string  `yaml:"image" toml:"image" env:"SANDBOX_IMAGE"`
+	MemoryMB     int     `yaml:"memory_mb" toml:"memory_mb" env:"SANDBOX_MEMORY_MB"`
+	CPUs         float64 `yaml:"cpus" toml:"cpus" env:"SANDBOX_CPUS"`
+	Network      bool    `yaml:"network" toml:"network" env:"SANDBOX_NETWORK"`
+	PerUser      int     `yaml:"per_user" toml:"per_user" env:"SANDBOX_PER_USER"`
+	Total        int     `yaml:"total" toml:"total" env:"SANDBOX_TOTAL"`
+	IdleMinutes  int     `yaml:"idle_minutes" toml:"idle_minutes" env:"SANDBOX_IDLE_MINUTES"`
+	MaxMinutes   int     `yaml:"max_minutes" toml:"max_minutes" env:"SANDBOX_MAX_MINUTES"`
+	// VerifyHour - час ночной проверки лаб, -1 - выключена
+	VerifyHour int `yaml:"verify_hour" toml:"verify_hour" env:"VERIFY_HOUR"`
+}
+
+// Attachments - вложения лаб и хранилище их содержимого
+type Attachments struct {
+	// Store - fs (каталог Dir) или s3
+	Store string `yaml:"store" toml:"store" env:"BLOB_STORE"`
+	Dir   string `yaml:"dir" toml:"dir" env:"BLOB_DIR"`
+	MaxMB int    `yaml:"max_mb" toml:"max_mb" env:"ATTACHMENT_MAX_MB"`
+	S3    S3     `yaml:"s3" toml:"s3"`
+}
+
+// S3 - S3-совместимое хранилище; пустой Endpoint - AWS в регионе Region
+type S3 struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint" env:"S3_ENDPOINT"`
+	Region    string `yaml:"region" toml:"region" env:"S3_REGION"`
+	Bucket    string `yaml:"bucket" toml:"bucket" env:"S3_BUCKET"`
+	AccessKey string `yaml:"access_key" toml:"access_key" env:"S3_ACCESS_KEY" secret:"true"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key" env:"S3_SECRET_KEY" secret:"true"`
+}
+
+// Default - значения, с которыми приложение работало до появления файла настроек.
+// Пароля админа и режима TLS базы по умолчанию нет: их задают явно.
+func Default() *Config {
+	return &Config{
+		Server:   Server{Port: 8080},
+		Database: Database{Port: 5432},
+		OIDC: OIDC{
+			Scopes:      "openid profile email",
+			GroupsClaim: "groups",
+		},
+		Registration: Registration{Mode: "closed"},
+		Trash:        Trash{RetentionDays: 30},
+		Sandbox: Sandbox{
+			Image:       "ubuntu:24.04",
+			MemoryMB:    256,
+			CPUs:        0.5,
+			PerUser:     1,
+			Total:       20,
+			IdleMinutes: 10,
+			MaxMinutes:  60,
+			VerifyHour:  3,
+		},
+		Attachments: Attachments{
+			Store: "fs",
+			Dir:   "data/blobs",
+			MaxMB: 10,
+			S3:    S3{Region: "us-east-1"},
+		},
+	}
+}
+
+// DSN - строка подключения libpq; значения в кавычках, чтобы пароль с
+// пробелами или кавычками не ломал разбор
+func (d *Database) DSN() string {
+	parts := []string{
+		"host=" + dsnQuote(d.Host),
+		fmt.Sprintf("port=%d", d.Port),
+		"user=" + dsnQuote(d.User),
+		"password=" + dsnQuote(d.Password),
+		"dbname=" + dsnQuote(d.Name),
+	}
+	if d.SSLMode != "" {
+		parts = append(parts, "sslmode="+dsnQuote(d.SSLMode))
+	}
+	if d.SSLRootCert != "" {
+		parts = append(parts, "sslrootcert="+dsnQuote(d.SSLRootCert))
+	}
+	return strings.Join(parts, " ")
+}
+
+func dsnQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultHasNoInsecureValues(t *testing.T) {
+	cfg := Default()
+	if cfg.Admin.Password != "" {
+		t.Errorf("default admin.password = %q, want empty", cfg.Admin.Password)
+	}
+	if cfg.Database.SSLMode != "" {
+		t.Errorf("default database.sslmode = %q, want empty", cfg.Database.SSLMode)
+	}
+	if dsn := cfg.Database.DSN(); strings.Contains(dsn, "sslmode") {
+		t.Errorf("DSN without sslmode sets it: %s", dsn)
+	}
+
+	for _, err := range cfg.SecurityProblems() {
+		if strings.HasPrefix(err.Error(), "admin.password") {
+			t.Errorf("empty admin.password reported as a problem: %v", err)
+		}
+	}
+}
+
+func TestValidateAdmin(t *testing.T) {
+	cfg := Default()
+	errs := cfg.ValidateAdmin()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "admin.password (ADMIN_PASSWORD): is required") {
+		t.Fatalf("ValidateAdmin = %v, want admin.password is required", errs)
+	}
+
+	cfg.Admin.Password = "long-enough-secret"
+	if errs := cfg.ValidateAdmin(); len(errs) != 0 {
+		t.Errorf("ValidateAdmin with password = %v", errs)
+	}
+}
+
+// isolateEnv сбрасывает переменные окружения всех ключей: пустые значения не учитываются
+func isolateEnv(t *testing.T) {
+	t.Helper()
+	for _, f := range fields {
+		t.Setenv(f.env, "")
+		t.Setenv(f.env+"_FILE", "")
+	}
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const baseYAML = `
+server:
+  port: 9000
+database:
+  host: file-db
+  user: manual
+  name: manual
+  sslmode: disable
+`
+
+func TestLoadPrecedence(t *testing.T) {
+	isolateEnv(t)
+	path := writeFile(t, "config.yaml", baseYAML)
+
+	cfg, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 9000 || cfg.Database.Host != "file-db" || cfg.Database.Port != 5432 {
+		t.Errorf("file over defaults: port %d, db %s:%d", cfg.Server.Port, cfg.Database.Host, cfg.Database.Port)
+	}
+
+	// Окружение перекрывает файл, -set перекрывает окружение
+	t.Setenv("DB_HOST", "env-db")
+	t.Setenv("SERVER_PORT", "9100")
+	cfg, err = Load(path, []string{"database.host=flag-db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Host != "flag-db" || cfg.Server.Port != 9100 {
+		t.Errorf("got db host %q, port %d; want flag-db, 9100", cfg.Database.Host, cfg.Server.Port)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	isolateEnv(t)
+	path := writeFile(t, "config.toml", `
+[database]
+host = "toml-db"
+user = "manual"
+name = "manual"
+
+[sandbox]
+cpus = 1.5
+`)
+	cfg, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Host != "toml-db" || cfg.Sandbox.CPUs != 1.5 {
+		t.Errorf("got db host %q, cpus %g", cfg.Database.Host, cfg.Sandbox.CPUs)
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	isolateEnv(t)
+	path := writeFile(t, "config.yaml", baseYAML)
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "s3cret pass\n"))
+
+	cfg, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "s3cret pass" {
+		t.Errorf("password from _FILE = %q", cfg.Database.Password)
+	}
+
+	t.Setenv("DB_PASSWORD", "inline")
+	_, err = Load(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "DB_PASSWORD and DB_PASSWORD_FILE are both set") {
+		t.Errorf("both DB_PASSWORD and DB_PASSWORD_FILE: %v", err)
+	}
+
+	t.Setenv("DB_PASSWORD", "")
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := Load(path, nil); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE:") {
+		t.Errorf("missing _FILE: %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	isolateEnv(t)
+	tests := []struct {
+		name      string
+		file      string
+		data      string
+		overrides []string
+		want      []string
+	}{
+		{"unknown yaml key", "config.yaml", baseYAML + "  hostname: db\n", nil,
+			[]string{"field hostname not found"}},
+		{"unknown toml key", "config.toml", "[database]\nhost = \"db\"\nuser = \"u\"\nname = \"n\"\nhostname = \"db\"\n", nil,
+			[]string{"unknown key database.hostname"}},
+		{"unknown -set key", "config.yaml", baseYAML, []string{"database.hostname=db"},
+			[]string{`-set database.hostname=db: unknown key "database.hostname"`}},
+		{"-set without value", "config.yaml", baseYAML, []string{"database.host"},
+			[]string{"-set database.host: want key=value"}},
+		// Все ошибки сразу, а не первая
+		{"several", "config.yaml", "server:\n  port: http\n", []string{"sandbox.cpus=many"},
+			[]string{"line 2: cannot unmarshal", "-set sandbox.cpus: invalid number", "database.host", "database.user"}},
+		{"unknown format", "config.ini", "", nil, []string{`unknown config format ".ini"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeFile(t, tt.file, tt.data), tt.overrides)
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error does not mention %q:\n%v", w, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "db-secret"
+	cfg.Attachments.S3.AccessKey = "AKIAEXAMPLE"
+	cfg.Attachments.S3.SecretKey = "s3-secret"
+	cfg.Database.Host = "db"
+
+	r := cfg.Redacted()
+	if r.Database.Password != "<redacted>" || r.Attachments.S3.AccessKey != "<redacted>" || r.Attachments.S3.SecretKey != "<redacted>" {
+		t.Errorf("secrets not redacted: %+v", r)
+	}
+	if r.Admin.Password != "" || r.Database.Host != "db" {
+		t.Errorf("unset secret or plain value changed: admin %q, host %q", r.Admin.Password, r.Database.Host)
+	}
+	if cfg.Database.Password != "db-secret" {
+		t.Error("Redacted modified the original config")
+	}
+
+	out, err := cfg.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"db-secret", "AKIAEXAMPLE", "s3-secret"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("-print-config output contains %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(string(out), "host: db\n") {
+		t.Errorf("-print-config output misses plain values:\n%s", out)
+	}
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Errors - все ошибки конфигурации, найденные за один проход
+type Errors []error
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  - " + err.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(lines, "\n")
+}
+
+// Overrides - значение флага -set; флаг можно повторять
+type Overrides []string
+
+func (o *Overrides) String() string { return strings.Join(*o, ", ") }
+
+func (o *Overrides) Set(s string) error {
+	*o = append(*o, s)
+	return nil
+}
+
+// Load собирает конфигурацию из файла path (пусто - без файла), окружения и
+// overrides вида "database.host=db" и проверяет её. Конфигурация возвращается
+// и вместе с ошибками, чтобы -print-config мог показать, что получилось.
+func Load(path string, overrides []string) (*Config, error) {
+	cfg := Default()
+	var errs Errors
+
+	if path != "" {
+		errs = append(errs, cfg.loadFile(path)...)
+	}
+	errs = append(errs, cfg.loadEnv(os.LookupEnv)...)
+	for _, o := range overrides {
+		if err := cfg.set(o); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, cfg.Validate()...)
+
+	if len(errs) > 0 {
+		return cfg, errs
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err := dec.Decode(c)
+		var typeErr *yaml.TypeError
+		switch {
+		case err == nil, errors.Is(err, io.EOF): // пустой файл - не ошибка
+		case errors.As(err, &typeErr):
+			// Остальные ключи при этом прочитаны, сообщаем о каждой ошибке
+			var errs []error
+			for _, e := range typeErr.Errors {
+				errs = append(errs, fmt.Errorf("%s: %s", path, e))
+			}
+			return errs
+		default:
+			return []error{fmt.Errorf("%s: %v", path, err)}
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			var strict *toml.StrictMissingError
+			if !errors.As(err, &strict) {
+				return []error{fmt.Errorf("%s: %v", path, err)}
+			}
+			// Известные ключи при этом уже прочитаны, сообщаем о каждом лишнем
+			var errs []error
+			for _, e := range strict.Errors {
+				row, _ := e.Position()
+				errs = append(errs, fmt.Errorf("%s:%d: unknown key %s", path, row, strings.Join(e.Key(), ".")))
+			}
+			return errs
+		}
+	default:
+		return []error{fmt.Errorf("%s: unknown config format %q (want .yaml, .yml or .toml)", path, ext)}
+	}
+	return nil
+}
+
+// loadEnv применяет переменные окружения; lookup - os.LookupEnv
+func (c *Config) loadEnv(lookup func(string) (string, bool)) []error {
+	var errs []error
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range fields {
+		value, _ := lookup(f.env)
+		file, _ := lookup(f.env + "_FILE")
+		if value != "" && file != "" {
+			errs = append(errs, fmt.Errorf("%s and %s_FILE are both set", f.env, f.env))
+			continue
+		}
+		if file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s_FILE: %v", f.env, err))
+				continue
+			}
+			// Редакторы и echo добавляют перевод строки в конце
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+		if value == "" {
+			continue
+		}
+		if err := setValue(v.FieldByIndex(f.index), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", f.env, err))
+		}
+	}
+	return errs
+}
+
+// set применяет одно значение флага -set
+func (c *Config) set(override string) error {
+	key, value, ok := strings.Cut(override, "=")
+	if !ok {
+		return fmt.Errorf("-set %s: want key=value", override)
+	}
+	f, ok := fieldByKey(strings.TrimSpace(key))
+	if !ok {
+		return fmt.Errorf("-set %s: unknown key %q", override, key)
+	}
+	if err := setValue(reflect.ValueOf(c).Elem().FieldByIndex(f.index), value); err != nil {
+		return fmt.Errorf("-set %s: %v", f.key, err)
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// field - конечное поле Config: ключ в файле, переменная окружения и путь для reflect
+type field struct {
+	key    string
+	env    string
+	secret bool
+	index  []int
+}
+
+var fields = collectFields(reflect.TypeOf(Config{}), "", nil)
+
+func collectFields(t reflect.Type, prefix string, index []int) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := prefix + sf.Tag.Get("yaml")
+		idx := append(append([]int{}, index...), i)
+		if sf.Type.Kind() == reflect.Struct {
+			out = append(out, collectFields(sf.Type, key+".", idx)...)
+			continue
+		}
+		out = append(out, field{key: key, env: sf.Tag.Get("env"), secret: sf.Tag.Get("secret") == "true", index: idx})
+	}
+	return out
+}
+
+func fieldByKey(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Redacted - копия конфигурации с заменёнными секретами; пустые остаются пустыми,
+// чтобы было видно, что секрет не задан
+func (c *Config) Redacted() *Config {
+	r := *c
+	v := reflect.ValueOf(&r).Elem()
+	for _, f := range fields {
+		if fv := v.FieldByIndex(f.index); f.secret && fv.String() != "" {
+			fv.SetString("<redacted>")
+		}
+	}
+	return &r
+}
+
+// YAML - конфигурация в формате файла настроек, секреты скрыты
+func (c *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
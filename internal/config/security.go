@@ -0,0 +1,84 @@
+package config
+
+import (
+	"devops-manual/internal/models"
+	"net/url"
+	"strings"
+)
+
+// DefaultPasswords - пароли из примеров и прежних значений по умолчанию.
+// Учётка или база с таким паролем считается незащищённой.
+var DefaultPasswords = []string{"admin123", "admin", "password", "changeme", "CHANGE_THIS_PASSWORD"}
+
+// SecurityProblems возвращает небезопасные настройки. В production режиме
+// приложение с ними не запускается, -doctor показывает их всегда.
+func (c *Config) SecurityProblems() []error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, keyError(key, format, args...))
+	}
+	password := func(key, value string) {
+		switch {
+		case value == "":
+			fail(key, "is empty")
+		case isDefaultPassword(value):
+			fail(key, "is a known default password")
+		case len(value) < models.MinPasswordLength:
+			fail(key, "is shorter than %d characters", models.MinPasswordLength)
+		}
+	}
+	https := func(key, value string) {
+		if u, err := url.Parse(value); err == nil && u.Scheme != "https" {
+			fail(key, "%q is not an HTTPS URL", value)
+		}
+	}
+
+	// Пароль админа нужен только -create-admin, пустой ничем не опасен
+	if c.Admin.Password != "" {
+		password("admin.password", c.Admin.Password)
+	}
+	password("database.password", c.Database.Password)
+
+	switch c.Database.SSLMode {
+	case "verify-ca", "verify-full":
+		if c.Database.SSLRootCert == "" {
+			fail("database.sslrootcert", "is required to verify the server certificate with sslmode %s", c.Database.SSLMode)
+		}
+	case "", "require":
+		// Пустой режим у lib/pq означает require
+		fail("database.sslmode", "%q does not verify the server certificate; use verify-full", c.Database.SSLMode)
+	default:
+		fail("database.sslmode", "%q allows unencrypted connections; use verify-full", c.Database.SSLMode)
+	}
+
+	if !c.Server.SecureCookies {
+		fail("server.secure_cookies", "session cookies are sent without the Secure flag; serve over HTTPS and enable it")
+	}
+
+	// Наполовину настроенный Telegram молча теряет алерты
+	switch {
+	case c.Telegram.BotToken != "" && c.Telegram.ChatID == "":
+		fail("telegram.chat_id", "is required when telegram.bot_token is set")
+	case c.Telegram.BotToken == "" && c.Telegram.ChatID != "":
+		fail("telegram.bot_token", "is required when telegram.chat_id is set")
+	}
+
+	if c.OIDC.Issuer != "" {
+		https("oidc.issuer", c.OIDC.Issuer)
+		https("oidc.redirect_url", c.OIDC.RedirectURL)
+	}
+	if c.Attachments.Store == "s3" && c.Attachments.S3.Endpoint != "" {
+		https("attachments.s3.endpoint", c.Attachments.S3.Endpoint)
+	}
+
+	return errs
+}
+
+func isDefaultPassword(s string) bool {
+	for _, p := range DefaultPasswords {
+		if strings.EqualFold(s, p) {
+			return true
+		}
+	}
+	return false
+}
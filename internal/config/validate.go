@@ -0,0 +1,143 @@
+package config
+
+import (
+	"devops-manual/internal/models"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var (
+	sslModes          = []string{"", "disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	registrationModes = []string{"closed", "open", "invite"}
+	sandboxRunners    = []string{"", "docker", "fake"}
+	blobStores        = []string{"fs", "s3"}
+	roles             = []string{models.RoleReader, models.RoleEditor, models.RoleReviewer, models.RoleAdmin}
+)
+
+// Validate проверяет значения и возвращает все ошибки сразу
+func (c *Config) Validate() []error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, keyError(key, format, args...))
+	}
+	required := func(key, value string) {
+		if strings.TrimSpace(value) == "" {
+			fail(key, "is required")
+		}
+	}
+	oneOf := func(key, value string, allowed []string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		quoted := make([]string, len(allowed))
+		for i, a := range allowed {
+			quoted[i] = fmt.Sprintf("%q", a)
+		}
+		fail(key, "%q is not one of %s", value, strings.Join(quoted, ", "))
+	}
+	between := func(key string, value, min, max int) {
+		if value < min || value > max {
+			fail(key, "%d is out of range %d..%d", value, min, max)
+		}
+	}
+	positive := func(key string, value int) {
+		if value <= 0 {
+			fail(key, "must be positive, got %d", value)
+		}
+	}
+
+	between("server.port", c.Server.Port, 1, 65535)
+
+	required("database.host", c.Database.Host)
+	between("database.port", c.Database.Port, 1, 65535)
+	required("database.user", c.Database.User)
+	required("database.name", c.Database.Name)
+	oneOf("database.sslmode", c.Database.SSLMode, sslModes)
+	if c.Database.SSLRootCert != "" {
+		if _, err := os.Stat(c.Database.SSLRootCert); err != nil {
+			fail("database.sslrootcert", "%v", err)
+		}
+	}
+
+	if c.OIDC.Issuer != "" {
+		if u, err := url.Parse(c.OIDC.Issuer); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("oidc.issuer", "%q is not an absolute URL", c.OIDC.Issuer)
+		}
+		required("oidc.client_id", c.OIDC.ClientID)
+		required("oidc.redirect_url", c.OIDC.RedirectURL)
+		for _, pair := range strings.Split(c.OIDC.RoleMap, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || group == "" {
+				fail("oidc.role_map", "%q is not group=role", pair)
+				continue
+			}
+			oneOf("oidc.role_map", role, roles)
+		}
+	}
+
+	oneOf("registration.mode", c.Registration.Mode, registrationModes)
+
+	if c.Trash.RetentionDays < 0 {
+		fail("trash.retention_days", "must not be negative, got %d", c.Trash.RetentionDays)
+	}
+
+	oneOf("sandbox.runner", c.Sandbox.Runner, sandboxRunners)
+	if c.Sandbox.Runner != "" {
+		required("sandbox.image", c.Sandbox.Image)
+		positive("sandbox.memory_mb", c.Sandbox.MemoryMB)
+		if c.Sandbox.CPUs <= 0 {
+			fail("sandbox.cpus", "must be positive, got %g", c.Sandbox.CPUs)
+		}
+		positive("sandbox.per_user", c.Sandbox.PerUser)
+		positive("sandbox.total", c.Sandbox.Total)
+		positive("sandbox.idle_minutes", c.Sandbox.IdleMinutes)
+		positive("sandbox.max_minutes", c.Sandbox.MaxMinutes)
+		between("sandbox.verify_hour", c.Sandbox.VerifyHour, -1, 23)
+	}
+
+	oneOf("attachments.store", c.Attachments.Store, blobStores)
+	positive("attachments.max_mb", c.Attachments.MaxMB)
+	switch c.Attachments.Store {
+	case "fs":
+		required("attachments.dir", c.Attachments.Dir)
+	case "s3":
+		if c.Attachments.S3.Endpoint != "" {
+			if u, err := url.Parse(c.Attachments.S3.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				fail("attachments.s3.endpoint", "%q is not an absolute URL", c.Attachments.S3.Endpoint)
+			}
+		}
+		required("attachments.s3.bucket", c.Attachments.S3.Bucket)
+		required("attachments.s3.access_key", c.Attachments.S3.AccessKey)
+		required("attachments.s3.secret_key", c.Attachments.S3.SecretKey)
+	}
+
+	return errs
+}
+
+// ValidateAdmin - дополнительные проверки для -create-admin
+func (c *Config) ValidateAdmin() []error {
+	switch {
+	case strings.TrimSpace(c.Admin.Password) == "":
+		return []error{keyError("admin.password", "is required for -create-admin")}
+	case len(c.Admin.Password) > models.MaxPasswordBytes:
+		// bcrypt не принимает пароли длиннее 72 байт
+		return []error{keyError("admin.password", "is longer than %d bytes", models.MaxPasswordBytes)}
+	}
+	return nil
+}
+
+// keyError - ошибка значения key; к ключу добавляется переменная окружения,
+// чтобы было понятно, что исправлять
+func keyError(key, format string, args ...interface{}) error {
+	if f, ok := fieldByKey(key); ok && f.env != "" {
+		key += " (" + f.env + ")"
+	}
+	return fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"devops-manual/internal/models"
+	"fmt"
+	"net/url"
+)
+
+const attachmentSelect = `SELECT id, lab_id, blob_key, filename, content_type, size, COALESCE(user_id, 0), created_at FROM attachments`
+
+func scanAttachment(row rowScanner) (*models.Attachment, error) {
+	var a models.Attachment
+	err := row.Scan(&a.ID, &a.LabID, &a.BlobKey, &a.Filename, &a.ContentType, &a.Size, &a.UserID, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.URL = attachmentURL(&a)
+	return &a, nil
+}
+
+func attachmentURL(a *models.Attachment) string {
+	return fmt.Sprintf("/attachments/%d/%s", a.ID, url.PathEscape(a.Filename))
+}
+
+// CreateAttachment сохраняет метаданные вложения; блоб должен быть уже записан
+func (db *DB) CreateAttachment(a *models.Attachment) error {
+	err := db.QueryRow(`INSERT INTO attachments (lab_id, blob_key, filename, content_type, size, user_id)
+	                    VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0)) RETURNING id, created_at`,
+		a.LabID, a.BlobKey, a.Filename, a.ContentType, a.Size, a.UserID).Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		return err
+	}
+	a.URL = attachmentURL(a)
+	return nil
+}
+
+func (db *DB) GetAttachment(id int) (*models.Attachment, error) {
+	return scanAttachment(db.QueryRow(attachmentSelect+" WHERE id = $1", id))
+}
+
+// GetAttachments возвращает вложения лабы в порядке загрузки
+func (db *DB) GetAttachments(labID int) ([]models.Attachment, error) {
+	rows, err := db.Query(attachmentSelect+" WHERE lab_id = $1 ORDER BY id", labID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment удаляет метаданные; блоб уберёт очистка неиспользуемых
+func (db *DB) DeleteAttachment(id int) error {
+	_, err := db.Exec("DELETE FROM attachments WHERE id = $1", id)
+	return err
+}
+
+// IsBlobKeyUsed - ссылается ли на блоб хотя бы одно вложение
+func (db *DB) IsBlobKeyUsed(key string) (bool, error) {
+	var used bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM attachments WHERE blob_key = $1)", key).Scan(&used)
+	return used, err
+}
+
+// GetUsedBlobKeys возвращает ключи блобов, на которые ссылается хотя бы одно
+// вложение, включая вложения лаб в корзине
+func (db *DB) GetUsedBlobKeys() (map[string]bool, error) {
+	rows, err := db.Query("SELECT DISTINCT blob_key FROM attachments")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
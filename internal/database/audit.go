@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/models"
+	"fmt"
+	"strings"
+)
+
+// PageLimit приводит запрошенный размер страницы журнала аудита и жалоб к
+// применяемому: не больше 500, без лимита - 50 записей
+func PageLimit(n int) int {
+	switch {
+	case n <= 0:
+		return 50
+	case n > 500:
+		return 500
+	}
+	return n
+}
+
+// AddAuditEvent сохраняет событие журнала аудита
+func (db *DB) AddAuditEvent(e *models.AuditEvent) error {
+	query := `INSERT INTO audit_events (actor_id, actor_name, action, target_type, target_id, before_data, after_data, ip, user_agent)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	          RETURNING id, created_at`
+
+	return db.QueryRow(query, e.ActorID, e.ActorName, e.Action, e.TargetType, e.TargetID,
+		nullJSON(e.Before), nullJSON(e.After), e.IP, e.UserAgent).Scan(&e.ID, &e.CreatedAt)
+}
+
+// GetAuditEvents возвращает страницу событий (новые сверху) и общее число подходящих записей
+func (db *DB) GetAuditEvents(f models.AuditFilter) ([]models.AuditEvent, int, error) {
+	where, args := auditWhere(f)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_events"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf("%s%s ORDER BY id DESC LIMIT %d OFFSET %d", auditSelect, where, PageLimit(f.Limit), f.Offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, *e)
+	}
+	return events, total, rows.Err()
+}
+
+// ForEachAuditEvent обходит все подходящие события в хронологическом порядке
+// (Limit/Offset игнорируются) - для выгрузки без загрузки журнала в память
+func (db *DB) ForEachAuditEvent(f models.AuditFilter, fn func(*models.AuditEvent) error) error {
+	where, args := auditWhere(f)
+
+	rows, err := db.Query(auditSelect+where+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+const auditSelect = `SELECT id, actor_id, actor_name, action, target_type, target_id,
+		before_data, after_data, ip, user_agent, created_at
+	FROM audit_events`
+
+func auditWhere(f models.AuditFilter) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.ActorID != 0 {
+		add("actor_id = $%d", f.ActorID)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	var e models.AuditEvent
+	var actorID sql.NullInt64
+	var before, after []byte
+	err := row.Scan(&e.ID, &actorID, &e.ActorName, &e.Action, &e.TargetType, &e.TargetID,
+		&before, &after, &e.IP, &e.UserAgent, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		e.ActorID = &id
+	}
+	e.Before = before
+	e.After = after
+	return &e, nil
+}
+
+// nullJSON превращает пустой JSON в NULL, чтобы не писать в JSONB пустую строку
+func nullJSON(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
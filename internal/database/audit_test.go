@@ -0,0 +1,11 @@
+package database
+
+import "testing"
+
+func TestPageLimit(t *testing.T) {
+	for n, want := range map[int]int{-1: 50, 0: 50, 1: 1, 100: 100, 500: 500, 501: 500, 10000: 500} {
+		if got := PageLimit(n); got != want {
+			t.Errorf("PageLimit(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
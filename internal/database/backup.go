@@ -0,0 +1,507 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/backup"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// revisionActions - события журнала аудита, которые хранят снимки лабы и
+// выгружаются как её ревизии
+var revisionActions = []string{"lab.create", "lab.update"}
+
+// DumpBackup выгружает содержимое справочника одной транзакцией, чтобы снимок
+// был согласованным
+func (db *DB) DumpBackup() (*backup.Snapshot, error) {
+	tx, err := db.BeginTx(nil, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s := &backup.Snapshot{
+		Topics:      []backup.Topic{},
+		Tags:        []backup.Tag{},
+		Users:       []backup.User{},
+		Labs:        []backup.Lab{},
+		Revisions:   []backup.Revision{},
+		Attachments: []backup.Attachment{},
+	}
+
+	err = queryEach(tx, "SELECT slug, title, COALESCE(description, ''), created_at FROM topics ORDER BY slug", nil,
+		func(rows *sql.Rows) error {
+			var t backup.Topic
+			if err := rows.Scan(&t.Slug, &t.Title, &t.Description, &t.CreatedAt); err != nil {
+				return err
+			}
+			s.Topics = append(s.Topics, t)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryEach(tx, "SELECT slug, name, created_at FROM tags ORDER BY slug", nil,
+		func(rows *sql.Rows) error {
+			var t backup.Tag
+			if err := rows.Scan(&t.Slug, &t.Name, &t.CreatedAt); err != nil {
+				return err
+			}
+			s.Tags = append(s.Tags, t)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryEach(tx, `SELECT username, password_hash, is_admin, role, COALESCE(oidc_subject, ''), COALESCE(email, ''), created_at
+	                     FROM users ORDER BY id`, nil,
+		func(rows *sql.Rows) error {
+			var u backup.User
+			if err := rows.Scan(&u.Username, &u.PasswordHash, &u.IsAdmin, &u.Role, &u.OIDCSubject, &u.Email, &u.CreatedAt); err != nil {
+				return err
+			}
+			s.Users = append(s.Users, u)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	labs := `
+		SELECT t.slug, l.slug, l.title, COALESCE(l.content, ''), l.commands, COALESCE(l.difficulty, ''), l.status,
+		       l.publish_at, l.unpublish_at, COALESCE(au.username, ''),
+		       ARRAY(SELECT tg.slug FROM lab_tags lt JOIN tags tg ON tg.id = lt.tag_id
+		             WHERE lt.lab_id = l.id ORDER BY tg.slug),
+		       ARRAY(SELECT pt.slug || '/' || pl.slug FROM lab_prerequisites lp
+		             JOIN labs pl ON pl.id = lp.requires_id JOIN topics pt ON pt.id = pl.topic_id
+		             WHERE lp.lab_id = l.id AND pl.deleted_at IS NULL ORDER BY 1),
+		       l.expectations, l.variables, COALESCE(l.destructive_steps, '{}'), l.created_at, l.updated_at
+		FROM labs l
+		JOIN topics t ON t.id = l.topic_id
+		LEFT JOIN users au ON au.id = l.author_id
+		WHERE l.deleted_at IS NULL
+		ORDER BY t.slug, l.slug`
+	err = queryEach(tx, labs, nil, func(rows *sql.Rows) error {
+		var l backup.Lab
+		var expectations, variables []byte
+		var destructive []int64
+		err := rows.Scan(&l.Topic, &l.Slug, &l.Title, &l.Content, pq.Array(&l.Commands), &l.Difficulty, &l.Status,
+			&l.PublishAt, &l.UnpublishAt, &l.Author, pq.Array(&l.Tags), pq.Array(&l.Requires),
+			&expectations, &variables, pq.Array(&destructive), &l.CreatedAt, &l.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if len(expectations) > 0 {
+			l.Expectations = expectations
+		}
+		if len(variables) > 0 {
+			l.Variables = variables
+		}
+		for _, step := range destructive {
+			l.DestructiveSteps = append(l.DestructiveSteps, int(step))
+		}
+		s.Labs = append(s.Labs, l)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := `
+		SELECT t.slug || '/' || l.slug, e.action, e.actor_name, e.before_data, e.after_data, e.created_at
+		FROM audit_events e
+		JOIN labs l ON e.target_type = 'lab' AND e.target_id = l.id::text
+		JOIN topics t ON t.id = l.topic_id
+		WHERE l.deleted_at IS NULL AND e.action = ANY($1)
+		ORDER BY e.id`
+	err = queryEach(tx, revisions, []interface{}{pq.Array(revisionActions)}, func(rows *sql.Rows) error {
+		var r backup.Revision
+		var before, after []byte
+		if err := rows.Scan(&r.Lab, &r.Action, &r.ActorName, &before, &after, &r.CreatedAt); err != nil {
+			return err
+		}
+		if len(before) > 0 {
+			r.Before = before
+		}
+		if len(after) > 0 {
+			r.After = after
+		}
+		s.Revisions = append(s.Revisions, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := `
+		SELECT a.id, t.slug || '/' || l.slug, a.blob_key, a.filename, a.content_type, a.size, a.created_at
+		FROM attachments a
+		JOIN labs l ON l.id = a.lab_id
+		JOIN topics t ON t.id = l.topic_id
+		WHERE l.deleted_at IS NULL
+		ORDER BY a.id`
+	err = queryEach(tx, attachments, nil, func(rows *sql.Rows) error {
+		var a backup.Attachment
+		if err := rows.Scan(&a.ID, &a.Lab, &a.BlobKey, &a.Filename, &a.ContentType, &a.Size, &a.CreatedAt); err != nil {
+			return err
+		}
+		s.Attachments = append(s.Attachments, a)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, tx.Commit()
+}
+
+func queryEach(tx *sql.Tx, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RestoreBackup вливает снимок в базу в режиме backup.ModeMerge или
+// backup.ModeReplace. Всё выполняется одной транзакцией: при ошибке база не
+// меняется, а dryRun откатывает транзакцию и только возвращает итог.
+// Архив с невалидными лабами не восстанавливается (backup.InvalidLabsError).
+//
+// Существующие лабы обновляются на месте, поэтому прогресс, оценки и
+// комментарии читателей к ним сохраняются.
+func (db *DB) RestoreBackup(s *backup.Snapshot, mode string, dryRun bool) (*backup.Stats, error) {
+	if mode != backup.ModeMerge && mode != backup.ModeReplace {
+		return nil, fmt.Errorf("unknown restore mode %q", mode)
+	}
+
+	// Лабы проверяются так же, как при сохранении через API; пробный прогон
+	// только перечисляет ошибки, чтобы архив можно было исправить за один раз
+	var stats backup.Stats
+	if invalid := s.ValidateLabs(); len(invalid) > 0 {
+		if !dryRun {
+			return nil, backup.InvalidLabsError(invalid)
+		}
+		stats.Invalid = invalid
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Темы и теги: содержимое из архива побеждает в обоих режимах.
+	// xmax = 0 у только что вставленной строки - так отличаем вставку от обновления.
+	topicIDs := make(map[string]int)
+	for _, t := range s.Topics {
+		var id int
+		var inserted bool
+		err := tx.QueryRow(`INSERT INTO topics (slug, title, description, created_at) VALUES ($1, $2, $3, $4)
+		                    ON CONFLICT (slug) DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description
+		                    RETURNING id, xmax = 0`, t.Slug, t.Title, t.Description, t.CreatedAt).Scan(&id, &inserted)
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %w", t.Slug, err)
+		}
+		topicIDs[t.Slug] = id
+		countChange(&stats.Topics, inserted)
+	}
+
+	tagIDs := make(map[string]int)
+	for _, t := range s.Tags {
+		var id int
+		var inserted bool
+		err := tx.QueryRow(`INSERT INTO tags (slug, name, created_at) VALUES ($1, $2, $3)
+		                    ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name
+		                    RETURNING id, xmax = 0`, t.Slug, t.Name, t.CreatedAt).Scan(&id, &inserted)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s: %w", t.Slug, err)
+		}
+		tagIDs[t.Slug] = id
+		countChange(&stats.Tags, inserted)
+	}
+
+	// Пользователи: merge только добавляет недостающих, replace ещё и
+	// перезаписывает хеш пароля, роль и привязки существующих
+	userIDs := make(map[string]int)
+	for _, u := range s.Users {
+		onConflict := "DO UPDATE SET username = EXCLUDED.username"
+		if mode == backup.ModeReplace {
+			onConflict = `DO UPDATE SET password_hash = EXCLUDED.password_hash, is_admin = EXCLUDED.is_admin,
+			              role = EXCLUDED.role, oidc_subject = EXCLUDED.oidc_subject, email = EXCLUDED.email`
+		}
+		var id int
+		var inserted bool
+		err := tx.QueryRow(`INSERT INTO users (username, password_hash, is_admin, role, oidc_subject, email, created_at)
+		                    VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7)
+		                    ON CONFLICT (username) `+onConflict+`
+		                    RETURNING id, xmax = 0`,
+			u.Username, u.PasswordHash, u.IsAdmin, u.Role, u.OIDCSubject, u.Email, u.CreatedAt).Scan(&id, &inserted)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		userIDs[u.Username] = id
+		if inserted || mode == backup.ModeReplace {
+			countChange(&stats.Users, inserted)
+		}
+	}
+
+	labIDs := make(map[string]int)
+	for i := range s.Labs {
+		l := &s.Labs[i]
+		topicID, ok := topicIDs[l.Topic]
+		if !ok {
+			if err := tx.QueryRow("SELECT id FROM topics WHERE slug = $1", l.Topic).Scan(&topicID); err != nil {
+				return nil, fmt.Errorf("lab %s: topic %s: %w", l.Key(), l.Topic, err)
+			}
+		}
+		authorID, ok := userIDs[l.Author]
+		if !ok && l.Author != "" {
+			// Автора нет в архиве - ищем в базе, иначе лаба остаётся без автора
+			tx.QueryRow("SELECT id FROM users WHERE username = $1", l.Author).Scan(&authorID)
+		}
+
+		var id int
+		err := tx.QueryRow("SELECT id FROM labs WHERE topic_id = $1 AND slug = $2 AND deleted_at IS NULL", topicID, l.Slug).Scan(&id)
+		switch err {
+		case nil:
+			_, err = tx.Exec(`UPDATE labs SET title = $2, content = $3, commands = $4, difficulty = $5, status = $6,
+			                      publish_at = $7, unpublish_at = $8, author_id = NULLIF($9, 0), expectations = $10,
+			                      variables = $11, destructive_steps = $12, created_at = $13, updated_at = $14
+			                  WHERE id = $1`,
+				id, l.Title, l.Content, pq.Array(l.Commands), l.Difficulty, l.Status, l.PublishAt, l.UnpublishAt,
+				authorID, rawOrNil(l.Expectations), rawOrNil(l.Variables), stepsOrNil(l.DestructiveSteps), l.CreatedAt, l.UpdatedAt)
+			stats.Labs.Updated++
+		case sql.ErrNoRows:
+			err = tx.QueryRow(`INSERT INTO labs (topic_id, slug, title, content, commands, difficulty, status, publish_at,
+			                       unpublish_at, author_id, expectations, variables, destructive_steps, created_at, updated_at)
+			                   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0), $11, $12, $13, $14, $15)
+			                   RETURNING id`,
+				topicID, l.Slug, l.Title, l.Content, pq.Array(l.Commands), l.Difficulty, l.Status, l.PublishAt, l.UnpublishAt,
+				authorID, rawOrNil(l.Expectations), rawOrNil(l.Variables), stepsOrNil(l.DestructiveSteps), l.CreatedAt, l.UpdatedAt).Scan(&id)
+			stats.Labs.Created++
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lab %s: %w", l.Key(), err)
+		}
+		labIDs[l.Key()] = id
+
+		if _, err := tx.Exec("DELETE FROM lab_tags WHERE lab_id = $1", id); err != nil {
+			return nil, err
+		}
+		for _, slug := range l.Tags {
+			tagID, ok := tagIDs[slug]
+			if !ok {
+				// Тег не выгружен отдельно (архив собран вручную) - создаём по slug
+				err := tx.QueryRow(`INSERT INTO tags (name, slug) VALUES ($1, $1)
+				                    ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+				                    RETURNING id`, slug).Scan(&tagID)
+				if err != nil {
+					return nil, fmt.Errorf("lab %s: tag %s: %w", l.Key(), slug, err)
+				}
+				tagIDs[slug] = tagID
+			}
+			if _, err := tx.Exec("INSERT INTO lab_tags (lab_id, tag_id) VALUES ($1, $2)", id, tagID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Пререквизиты - после всех лаб, они ссылаются друг на друга
+	for i := range s.Labs {
+		l := &s.Labs[i]
+		id := labIDs[l.Key()]
+		if _, err := tx.Exec("DELETE FROM lab_prerequisites WHERE lab_id = $1", id); err != nil {
+			return nil, err
+		}
+		for _, req := range l.Requires {
+			reqID, ok := labIDs[req]
+			if !ok {
+				parts := strings.SplitN(req, "/", 2)
+				if len(parts) != 2 {
+					return nil, fmt.Errorf("lab %s: invalid prerequisite %q", l.Key(), req)
+				}
+				err := tx.QueryRow(`SELECT l.id FROM labs l JOIN topics t ON t.id = l.topic_id
+				                    WHERE t.slug = $1 AND l.slug = $2 AND l.deleted_at IS NULL`, parts[0], parts[1]).Scan(&reqID)
+				if err != nil {
+					return nil, fmt.Errorf("lab %s: prerequisite %s: %w", l.Key(), req, err)
+				}
+			}
+			if _, err := tx.Exec("INSERT INTO lab_prerequisites (lab_id, requires_id) VALUES ($1, $2)", id, reqID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if mode == backup.ModeReplace {
+		ids := make(pq.Int64Array, 0, len(labIDs))
+		for _, id := range labIDs {
+			ids = append(ids, int64(id))
+		}
+		// Лишние лабы - в корзину, их можно будет вернуть
+		res, err := tx.Exec("UPDATE labs SET deleted_at = NOW() WHERE deleted_at IS NULL AND id <> ALL($1)", ids)
+		if err != nil {
+			return nil, err
+		}
+		stats.Labs.Deleted = affected(res)
+
+		res, err = tx.Exec("DELETE FROM tags WHERE slug <> ALL($1)", pq.Array(mapKeys(tagIDs)))
+		if err != nil {
+			return nil, err
+		}
+		stats.Tags.Deleted = affected(res)
+
+		// Темы с лабами в корзине остаются, чтобы не потерять корзину каскадом
+		res, err = tx.Exec(`DELETE FROM topics WHERE slug <> ALL($1)
+		                    AND NOT EXISTS (SELECT 1 FROM labs WHERE labs.topic_id = topics.id)`, pq.Array(mapKeys(topicIDs)))
+		if err != nil {
+			return nil, err
+		}
+		stats.Topics.Deleted = affected(res)
+	}
+
+	// Вложения: блобы должны быть уже записаны в хранилище. В replace набор
+	// вложений восстановленной лабы приводится к архиву, в merge только дополняется.
+	// Новые id запоминаются, чтобы переписать ссылки на вложения в тексте лаб.
+	// Attachments == nil - архив версии 1, о вложениях он ничего не знает.
+	attachmentIDs := make(map[int]int)
+	if mode == backup.ModeReplace && s.Attachments != nil {
+		for _, l := range s.Labs {
+			id := labIDs[l.Key()]
+			res, err := tx.Exec(`DELETE FROM attachments a WHERE lab_id = $1 AND NOT EXISTS (
+			                         SELECT 1 FROM unnest($2::text[], $3::text[]) AS keep(blob_key, filename)
+			                         WHERE keep.blob_key = a.blob_key AND keep.filename = a.filename)`,
+				id, pq.Array(attachmentField(s.Attachments, l.Key(), func(a backup.Attachment) string { return a.BlobKey })),
+				pq.Array(attachmentField(s.Attachments, l.Key(), func(a backup.Attachment) string { return a.Filename })))
+			if err != nil {
+				return nil, err
+			}
+			stats.Attachments.Deleted += affected(res)
+		}
+	}
+	for _, a := range s.Attachments {
+		labID, ok := labIDs[a.Lab]
+		if !ok {
+			return nil, fmt.Errorf("attachment %s: unknown lab %s", a.Filename, a.Lab)
+		}
+		var id int
+		err := tx.QueryRow("SELECT id FROM attachments WHERE lab_id = $1 AND blob_key = $2 AND filename = $3 ORDER BY id LIMIT 1",
+			labID, a.BlobKey, a.Filename).Scan(&id)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRow(`INSERT INTO attachments (lab_id, blob_key, filename, content_type, size, created_at)
+			                   VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+				labID, a.BlobKey, a.Filename, a.ContentType, a.Size, a.CreatedAt).Scan(&id)
+			stats.Attachments.Created++
+		}
+		if err != nil {
+			return nil, fmt.Errorf("attachment %s of %s: %w", a.Filename, a.Lab, err)
+		}
+		attachmentIDs[a.ID] = id
+	}
+	for i := range s.Labs {
+		l := &s.Labs[i]
+		content := rewriteAttachmentLinks(l.Content, attachmentIDs)
+		if content == l.Content {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE labs SET content = $2 WHERE id = $1", labIDs[l.Key()], content); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ревизии привязываются к новым id лаб; уже имеющиеся не дублируются
+	for _, r := range s.Revisions {
+		id, ok := labIDs[r.Lab]
+		if !ok {
+			continue
+		}
+		res, err := tx.Exec(`INSERT INTO audit_events (actor_name, action, target_type, target_id, before_data, after_data, created_at)
+		                     SELECT $1, $2, 'lab', $3, $4, $5, $6
+		                     WHERE NOT EXISTS (SELECT 1 FROM audit_events
+		                                       WHERE target_type = 'lab' AND target_id = $3 AND action = $2 AND created_at = $6)`,
+			r.ActorName, r.Action, strconv.Itoa(id), rawOrNil(r.Before), rawOrNil(r.After), r.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("revision of %s: %w", r.Lab, err)
+		}
+		stats.Revisions.Created += affected(res)
+	}
+
+	if dryRun {
+		return &stats, nil
+	}
+
+	after, _ := json.Marshal(map[string]interface{}{"mode": mode, "stats": stats})
+	_, err = tx.Exec(`INSERT INTO audit_events (actor_name, action, target_type, after_data)
+	                  VALUES ('cli', 'backup.restore', 'backup', $1)`, string(after))
+	if err != nil {
+		return nil, err
+	}
+	return &stats, tx.Commit()
+}
+
+var attachmentLinkRe = regexp.MustCompile(`/attachments/(\d+)/`)
+
+// rewriteAttachmentLinks заменяет id в ссылках /attachments/<id>/ за один проход,
+// чтобы замены не накладывались друг на друга (1 -> 2, затем 2 -> 3)
+func rewriteAttachmentLinks(content string, ids map[int]int) string {
+	return attachmentLinkRe.ReplaceAllStringFunc(content, func(m string) string {
+		old, _ := strconv.Atoi(attachmentLinkRe.FindStringSubmatch(m)[1])
+		if id, ok := ids[old]; ok {
+			return "/attachments/" + strconv.Itoa(id) + "/"
+		}
+		return m
+	})
+}
+
+// attachmentField - значения поля вложений лабы lab из архива
+func attachmentField(attachments []backup.Attachment, lab string, field func(backup.Attachment) string) []string {
+	values := []string{}
+	for _, a := range attachments {
+		if a.Lab == lab {
+			values = append(values, field(a))
+		}
+	}
+	return values
+}
+
+func countChange(c *backup.Change, inserted bool) {
+	if inserted {
+		c.Created++
+	} else {
+		c.Updated++
+	}
+}
+
+func affected(res sql.Result) int {
+	n, _ := res.RowsAffected()
+	return int(n)
+}
+
+func mapKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// rawOrNil - JSONB значение из архива: пустое пишется как NULL
+func rawOrNil(b json.RawMessage) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
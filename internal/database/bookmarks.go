@@ -0,0 +1,256 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"devops-manual/internal/models"
+	"encoding/hex"
+	"fmt"
+)
+
+// AddBookmark добавляет лабу в закладки (повторное добавление ничего не меняет)
+func (db *DB) AddBookmark(userID, labID int) error {
+	_, err := db.Exec("INSERT INTO bookmarks (user_id, lab_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, labID)
+	return err
+}
+
+func (db *DB) RemoveBookmark(userID, labID int) error {
+	res, err := db.Exec("DELETE FROM bookmarks WHERE user_id = $1 AND lab_id = $2", userID, labID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DB) IsBookmarked(userID, labID int) (bool, error) {
+	var ok bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM bookmarks WHERE user_id = $1 AND lab_id = $2)",
+		userID, labID).Scan(&ok)
+	return ok, err
+}
+
+// GetBookmarks возвращает закладки пользователя, новые сверху; удалённые лабы
+// пропускаются, publishedOnly скрывает неопубликованные
+func (db *DB) GetBookmarks(userID int, publishedOnly bool) ([]models.Bookmark, error) {
+	rows, err := db.Query(`SELECT b.lab_id, b.created_at FROM bookmarks b
+	                       JOIN labs l ON l.id = b.lab_id
+	                       WHERE b.user_id = $1 AND l.deleted_at IS NULL AND (NOT $2 OR l.status = 'published')
+	                       ORDER BY b.created_at DESC`, userID, publishedOnly)
+	if err != nil {
+		return nil, err
+	}
+	var list []models.Bookmark
+	for rows.Next() {
+		var b models.Bookmark
+		if err := rows.Scan(&b.LabID, &b.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	bookmarks := []models.Bookmark{}
+	for _, b := range list {
+		lab, err := db.GetLabByID(b.LabID)
+		if err != nil {
+			return nil, err
+		}
+		b.Lab = lab
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, nil
+}
+
+const collectionSelect = `
+	SELECT c.id, c.user_id, u.username, c.name, COALESCE(c.share_token, ''), c.created_at, c.updated_at
+	FROM collections c
+	JOIN users u ON u.id = c.user_id`
+
+func scanCollection(row rowScanner) (*models.Collection, error) {
+	var c models.Collection
+	err := row.Scan(&c.ID, &c.UserID, &c.Owner, &c.Name, &c.ShareToken, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// loadCollectionLabs заполняет лабы подборки в сохранённом порядке
+func (db *DB) loadCollectionLabs(c *models.Collection, publishedOnly bool) error {
+	rows, err := db.Query(labSelect+`
+		JOIN collection_items ci ON ci.lab_id = l.id
+		WHERE ci.collection_id = $1 AND l.deleted_at IS NULL AND (NOT $2 OR l.status = 'published')
+		ORDER BY ci.position`, c.ID, publishedOnly)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	c.Labs = []models.Lab{}
+	c.LabIDs = []int{}
+	for rows.Next() {
+		l, err := scanLab(rows)
+		if err != nil {
+			return err
+		}
+		c.Labs = append(c.Labs, *l)
+		c.LabIDs = append(c.LabIDs, l.ID)
+	}
+	return rows.Err()
+}
+
+// GetCollections возвращает подборки пользователя с лабами
+func (db *DB) GetCollections(userID int, publishedOnly bool) ([]models.Collection, error) {
+	rows, err := db.Query(collectionSelect+" WHERE c.user_id = $1 ORDER BY c.name", userID)
+	if err != nil {
+		return nil, err
+	}
+	var list []models.Collection
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, *c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if err := db.loadCollectionLabs(&list[i], publishedOnly); err != nil {
+			return nil, err
+		}
+	}
+	if list == nil {
+		list = []models.Collection{}
+	}
+	return list, nil
+}
+
+// GetCollection возвращает подборку пользователя; чужая подборка - sql.ErrNoRows
+func (db *DB) GetCollection(id, userID int, publishedOnly bool) (*models.Collection, error) {
+	c, err := scanCollection(db.QueryRow(collectionSelect+" WHERE c.id = $1 AND c.user_id = $2", id, userID))
+	if err != nil {
+		return nil, err
+	}
+	return c, db.loadCollectionLabs(c, publishedOnly)
+}
+
+// GetSharedCollection открывает подборку по ссылке, только опубликованные лабы
+func (db *DB) GetSharedCollection(token string) (*models.Collection, error) {
+	c, err := scanCollection(db.QueryRow(collectionSelect+" WHERE c.share_token = $1", token))
+	if err != nil {
+		return nil, err
+	}
+	return c, db.loadCollectionLabs(c, true)
+}
+
+func (db *DB) CreateCollection(c *models.Collection) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow("INSERT INTO collections (user_id, name) VALUES ($1, $2) RETURNING id, created_at, updated_at",
+		c.UserID, c.Name).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := insertCollectionItems(tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateCollection меняет название и полностью заменяет список лаб
+func (db *DB) UpdateCollection(c *models.Collection) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE collections SET name = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3",
+		c.Name, c.ID, c.UserID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec("DELETE FROM collection_items WHERE collection_id = $1", c.ID); err != nil {
+		return err
+	}
+	if err := insertCollectionItems(tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteCollection(id, userID int) error {
+	res, err := db.Exec("DELETE FROM collections WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetCollectionShared выдаёт новую ссылку на подборку (shared) или отзывает её.
+// Возвращает токен ссылки, пустой при отзыве.
+func (db *DB) SetCollectionShared(id, userID int, shared bool) (string, error) {
+	var token interface{}
+	if shared {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		token = hex.EncodeToString(b)
+	}
+
+	res, err := db.Exec("UPDATE collections SET share_token = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3",
+		token, id, userID)
+	if err != nil {
+		return "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", sql.ErrNoRows
+	}
+	if token == nil {
+		return "", nil
+	}
+	return token.(string), nil
+}
+
+func insertCollectionItems(tx *sql.Tx, c *models.Collection) error {
+	for i, labID := range c.LabIDs {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM labs WHERE id = $1 AND deleted_at IS NULL)", labID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: id %d", ErrUnknownLab, labID)
+		}
+
+		if _, err := tx.Exec(`INSERT INTO collection_items (collection_id, lab_id, position) VALUES ($1, $2, $3)
+		                      ON CONFLICT DO NOTHING`, c.ID, labID, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"devops-manual/internal/models"
+	"encoding/json"
+	"time"
+)
+
+// CreateLabCheck сохраняет результат проверки лабы
+func (db *DB) CreateLabCheck(c *models.LabCheck) error {
+	steps, err := json.Marshal(c.Steps)
+	if err != nil {
+		return err
+	}
+	return db.QueryRow(`INSERT INTO lab_checks (lab_id, passed, trigger, user_id, error, steps, duration_ms)
+	                    VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7) RETURNING id, created_at`,
+		c.LabID, c.Passed, c.Trigger, c.UserID, c.Error, string(steps), c.DurationMs).Scan(&c.ID, &c.CreatedAt)
+}
+
+// GetLabChecks возвращает последние проверки лабы, новые сверху
+func (db *DB) GetLabChecks(labID, limit int) ([]models.LabCheck, error) {
+	rows, err := db.Query(`
+		SELECT c.id, c.lab_id, c.passed, c.trigger, COALESCE(c.user_id, 0), COALESCE(u.username, ''),
+		       c.error, c.steps, c.duration_ms, c.created_at
+		FROM lab_checks c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.lab_id = $1
+		ORDER BY c.id DESC
+		LIMIT $2`, labID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []models.LabCheck{}
+	for rows.Next() {
+		var c models.LabCheck
+		var steps []byte
+		err := rows.Scan(&c.ID, &c.LabID, &c.Passed, &c.Trigger, &c.UserID, &c.Username,
+			&c.Error, &steps, &c.DurationMs, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(steps, &c.Steps); err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// ClaimNightlyChecks отмечает ночной прогон за день; false - его уже начала другая реплика
+func (db *DB) ClaimNightlyChecks(day time.Time) (bool, error) {
+	res, err := db.Exec("INSERT INTO lab_check_runs (day) VALUES ($1) ON CONFLICT DO NOTHING", day.Format("2006-01-02"))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// GetVerifiableLabs - опубликованные лабы с командами для ночной проверки
+func (db *DB) GetVerifiableLabs() ([]models.Lab, error) {
+	rows, err := db.Query(labSelect + `
+		WHERE l.deleted_at IS NULL AND l.status = 'published' AND cardinality(l.commands) > 0
+		ORDER BY l.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labs := []models.Lab{}
+	for rows.Next() {
+		l, err := scanLab(rows)
+		if err != nil {
+			return nil, err
+		}
+		labs = append(labs, *l)
+	}
+	return labs, rows.Err()
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/markdown"
+	"devops-manual/internal/models"
+	"errors"
+)
+
+// ErrInvalidParent - ответ на комментарий другой лабы или несуществующий
+var ErrInvalidParent = errors.New("parent comment not found in this lab")
+
+const commentSelect = `
+	SELECT c.id, c.lab_id, c.parent_id, COALESCE(c.user_id, 0), COALESCE(u.username, ''),
+	       c.step, c.body, c.hidden, c.deleted_at IS NOT NULL, c.created_at, c.updated_at
+	FROM comments c
+	LEFT JOIN users u ON u.id = c.user_id`
+
+func scanComment(row rowScanner) (*models.Comment, error) {
+	var c models.Comment
+	var parentID, step sql.NullInt64
+	var updatedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.LabID, &parentID, &c.UserID, &c.Author,
+		&step, &c.Body, &c.Hidden, &c.Deleted, &c.CreatedAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	if step.Valid {
+		n := int(step.Int64)
+		c.Step = &n
+	}
+	if updatedAt.Valid {
+		c.UpdatedAt = &updatedAt.Time
+	}
+	if !c.Deleted {
+		c.HTML = markdown.Render(c.Body)
+	}
+	c.Replies = []*models.Comment{}
+	return &c, nil
+}
+
+// GetLabComments возвращает дерево комментариев лабы. Скрытые модератором
+// отдаются только при includeHidden. Удалённые автором остаются заглушками,
+// пока у них есть ответы, чтобы не рвать ветку.
+func (db *DB) GetLabComments(labID int, includeHidden bool) ([]*models.Comment, error) {
+	rows, err := db.Query(commentSelect+`
+		WHERE c.lab_id = $1 AND ($2 OR NOT c.hidden)
+		ORDER BY c.created_at, c.id`, labID, includeHidden)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []*models.Comment
+	byID := map[int]*models.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, c)
+		byID[c.ID] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	roots := []*models.Comment{}
+	for _, c := range all {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+		// Ответы на скрытые комментарии скрываются вместе с веткой
+	}
+	return pruneDeleted(roots), nil
+}
+
+// pruneDeleted убирает удалённые комментарии без живых ответов
+func pruneDeleted(list []*models.Comment) []*models.Comment {
+	kept := []*models.Comment{}
+	for _, c := range list {
+		c.Replies = pruneDeleted(c.Replies)
+		if c.Deleted && len(c.Replies) == 0 {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+func (db *DB) GetCommentByID(id int) (*models.Comment, error) {
+	return scanComment(db.QueryRow(commentSelect+" WHERE c.id = $1", id))
+}
+
+// CreateComment добавляет комментарий; ответ наследует лабу родителя и не имеет step
+func (db *DB) CreateComment(c *models.Comment) error {
+	if c.ParentID != nil {
+		var parentLab int
+		err := db.QueryRow("SELECT lab_id FROM comments WHERE id = $1 AND deleted_at IS NULL", *c.ParentID).Scan(&parentLab)
+		if err == sql.ErrNoRows || (err == nil && parentLab != c.LabID) {
+			return ErrInvalidParent
+		}
+		if err != nil {
+			return err
+		}
+		c.Step = nil
+	}
+
+	var id int
+	err := db.QueryRow(`INSERT INTO comments (lab_id, parent_id, user_id, step, body)
+	                    VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		c.LabID, c.ParentID, c.UserID, c.Step, c.Body).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	created, err := db.GetCommentByID(id)
+	if err != nil {
+		return err
+	}
+	*c = *created
+	return nil
+}
+
+// UpdateComment меняет текст неудалённого комментария
+func (db *DB) UpdateComment(id int, body string) error {
+	res, err := db.Exec("UPDATE comments SET body = $2, updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id, body)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteComment - удаление автором: текст стирается, ответы сохраняются
+func (db *DB) DeleteComment(id int) error {
+	res, err := db.Exec("UPDATE comments SET body = '', deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeComment - удаление модератором вместе со всеми ответами
+func (db *DB) PurgeComment(id int) error {
+	res, err := db.Exec("DELETE FROM comments WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetCommentHidden скрывает комментарий (и его ветку) от читателей или возвращает его
+func (db *DB) SetCommentHidden(id int, hidden bool) error {
+	res, err := db.Exec("UPDATE comments SET hidden = $2 WHERE id = $1", id, hidden)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -1,10 +1,17 @@
 package database
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"devops-manual/internal/config"
 	"devops-manual/internal/models"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lib/pq"
@@ -13,19 +20,15 @@ import (
 
 type DB struct {
 	*sql.DB
+
+	// Сессии создаются из обработчиков конкурентно, в том числе анонимными
+	// регистрациями, поэтому карта под мьютексом
+	mu       sync.RWMutex
 	sessions map[string]*models.Session
 }
 
-func New() (*DB, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	)
-
-	db, err := sql.Open("postgres", connStr)
+func New(cfg config.Database) (*DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +37,7 @@ func New() (*DB, error) {
 		return nil, err
 	}
 
-	return &DB{db, make(map[string]*models.Session)}, nil
+	return &DB{DB: db, sessions: make(map[string]*models.Session)}, nil
 }
 
 func (db *DB) InitSchema() error {
@@ -87,6 +90,223 @@ func (db *DB) InitSchema() error {
 	INSERT INTO users (username, password_hash, is_admin) 
 	VALUES ('admin', '$2a$10$YourHashedPasswordHere', true)
 	ON CONFLICT DO NOTHING;
+
+	-- Роли и привязка к учётке SSO
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(50) NOT NULL DEFAULT 'reader';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(255) UNIQUE;
+	UPDATE users SET role = 'admin' WHERE is_admin AND role <> 'admin';
+
+	-- Саморегистрация читателей: email и инвайт-коды
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+	CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (LOWER(email)) WHERE email IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS invite_codes (
+		code VARCHAR(64) PRIMARY KEY,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMPTZ,
+		used_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		used_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id BIGSERIAL PRIMARY KEY,
+		actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		actor_name VARCHAR(255) NOT NULL DEFAULT '',
+		action VARCHAR(100) NOT NULL,
+		target_type VARCHAR(50) NOT NULL DEFAULT '',
+		target_id VARCHAR(255) NOT NULL DEFAULT '',
+		before_data JSONB,
+		after_data JSONB,
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id);
+
+	-- Мягкое удаление: slug уникален только среди неудалённых лаб
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	ALTER TABLE labs DROP CONSTRAINT IF EXISTS labs_topic_id_slug_key;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_labs_topic_slug_active ON labs (topic_id, slug) WHERE deleted_at IS NULL;
+
+	-- Публикация: существующие лабы считаются опубликованными, новые создаются черновиками
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published';
+
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMPTZ;
+
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS author_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+	CREATE INDEX IF NOT EXISTS idx_labs_created_at ON labs (created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_labs_updated_at ON labs (updated_at, id);
+	CREATE INDEX IF NOT EXISTS idx_labs_title ON labs (title, id);
+
+	CREATE TABLE IF NOT EXISTS tags (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		slug VARCHAR(100) UNIQUE NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS lab_tags (
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (lab_id, tag_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_lab_tags_tag ON lab_tags (tag_id);
+
+	CREATE TABLE IF NOT EXISTS lab_prerequisites (
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		requires_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		PRIMARY KEY (lab_id, requires_id),
+		CHECK (lab_id <> requires_id)
+	);
+
+	-- Прогресс: step = -1 означает лабу целиком
+	CREATE TABLE IF NOT EXISTS progress (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		step INTEGER NOT NULL DEFAULT -1,
+		completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, lab_id, step)
+	);
+
+	-- Комментарии: step - индекс команды лабы, deleted_at - удалён автором
+	CREATE TABLE IF NOT EXISTS comments (
+		id SERIAL PRIMARY KEY,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		step INTEGER,
+		body TEXT NOT NULL,
+		hidden BOOLEAN NOT NULL DEFAULT false,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP,
+		deleted_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_comments_lab ON comments(lab_id, created_at);
+
+	-- Сообщения о проблемах; fixed_by_event_id - правка лабы из журнала аудита
+	CREATE TABLE IF NOT EXISTS lab_reports (
+		id SERIAL PRIMARY KEY,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		type VARCHAR(50) NOT NULL,
+		description TEXT NOT NULL,
+		environment TEXT NOT NULL DEFAULT '',
+		status VARCHAR(50) NOT NULL DEFAULT 'open',
+		fixed_by_event_id BIGINT REFERENCES audit_events(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_lab_reports_status ON lab_reports(lab_id, status);
+
+	-- Автоматическая проверка лаб: ожидаемый результат шагов и история запусков
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS expectations JSONB;
+
+	CREATE TABLE IF NOT EXISTS lab_checks (
+		id SERIAL PRIMARY KEY,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		passed BOOLEAN NOT NULL,
+		trigger VARCHAR(20) NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		error TEXT NOT NULL DEFAULT '',
+		steps JSONB NOT NULL DEFAULT '[]',
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_lab_checks_lab ON lab_checks(lab_id, id DESC);
+
+	-- Ночные прогоны: строка на день, чтобы при нескольких репликах проверка шла один раз
+	CREATE TABLE IF NOT EXISTS lab_check_runs (
+		day DATE PRIMARY KEY,
+		started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Переменные лаб: [{"name": "namespace", "default": "myapp", "description": "..."}]
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS variables JSONB;
+
+	-- Необратимые шаги лабы (индексы команд), скрипт спрашивает подтверждение
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS destructive_steps INTEGER[];
+
+	-- Правка опубликованной лабы от редактора до ревью (лаба целиком, JSON);
+	-- читатели видят прежнюю версию, пока ревьюер не примет правку
+	ALTER TABLE labs ADD COLUMN IF NOT EXISTS pending_revision JSONB;
+
+	-- Вложения лаб: содержимое в хранилище блобов под ключом SHA-256, здесь метаданные
+	CREATE TABLE IF NOT EXISTS attachments (
+		id SERIAL PRIMARY KEY,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		blob_key CHAR(64) NOT NULL,
+		filename VARCHAR(255) NOT NULL,
+		content_type VARCHAR(100) NOT NULL,
+		size BIGINT NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_lab ON attachments(lab_id);
+	CREATE INDEX IF NOT EXISTS idx_attachments_blob ON attachments(blob_key);
+
+	-- Оценки лаб читателями: одна оценка 1-5 на пользователя
+	CREATE TABLE IF NOT EXISTS lab_ratings (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		score SMALLINT NOT NULL CHECK (score BETWEEN 1 AND 5),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, lab_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_lab_ratings_lab ON lab_ratings(lab_id, updated_at);
+
+	-- Закладки и подборки лаб пользователей
+	CREATE TABLE IF NOT EXISTS bookmarks (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, lab_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS collections (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		share_token VARCHAR(64) UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS collection_items (
+		collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		PRIMARY KEY (collection_id, lab_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS paths (
+		id SERIAL PRIMARY KEY,
+		title VARCHAR(255) NOT NULL,
+		slug VARCHAR(255) UNIQUE NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS path_items (
+		path_id INTEGER NOT NULL REFERENCES paths(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		lab_id INTEGER REFERENCES labs(id) ON DELETE CASCADE,
+		section VARCHAR(255) NOT NULL DEFAULT '',
+		PRIMARY KEY (path_id, position)
+	);
+
+	CREATE TABLE IF NOT EXISTS lab_reviews (
+		id SERIAL PRIMARY KEY,
+		lab_id INTEGER NOT NULL REFERENCES labs(id) ON DELETE CASCADE,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		action VARCHAR(50) NOT NULL,
+		comment TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	_, err := db.Exec(schema)
@@ -122,16 +342,75 @@ func (db *DB) GetTopicBySlug(slug string) (*models.Topic, error) {
 	return &t, nil
 }
 
-func (db *DB) GetLabsByTopicSlug(topicSlug string) ([]models.Lab, error) {
-	query := `
-		SELECT l.id, l.topic_id, l.title, l.slug, l.content, l.commands, l.difficulty, l.created_at, l.updated_at,
+// labSelect - общий SELECT лабы вместе с темой, используется всеми выборками лаб.
+// Удалённые в корзину лабы нужно отсекать условием l.deleted_at IS NULL.
+// Средняя оценка лабы (округлена, чтобы совпадать со значением в курсоре) и число оценок
+const (
+	ratingAvgExpr   = "COALESCE((SELECT ROUND(AVG(r.score), 2) FROM lab_ratings r WHERE r.lab_id = l.id), 0)"
+	ratingCountExpr = "(SELECT COUNT(*) FROM lab_ratings r WHERE r.lab_id = l.id)"
+	// Лаба устарела, если последняя проверка провалилась
+	outdatedExpr = "COALESCE((SELECT NOT c.passed FROM lab_checks c WHERE c.lab_id = l.id ORDER BY c.id DESC LIMIT 1), false)"
+)
+
+const labSelect = `
+		SELECT l.id, l.topic_id, l.title, l.slug, l.content, l.commands, l.difficulty, l.created_at, l.updated_at, l.deleted_at, l.status,
+		       l.publish_at, l.unpublish_at, COALESCE(l.author_id, 0), COALESCE(au.username, ''),
+		       ` + ratingAvgExpr + `, ` + ratingCountExpr + `,
+		       ARRAY(SELECT tg.slug FROM lab_tags lt JOIN tags tg ON tg.id = lt.tag_id
+		             WHERE lt.lab_id = l.id ORDER BY tg.slug),
+		       ARRAY(SELECT pt.slug || '/' || pl.slug FROM lab_prerequisites lp
+		             JOIN labs pl ON pl.id = lp.requires_id JOIN topics pt ON pt.id = pl.topic_id
+		             WHERE lp.lab_id = l.id AND pl.deleted_at IS NULL ORDER BY 1),
+		       l.expectations, ` + outdatedExpr + `, l.variables, COALESCE(l.destructive_steps, '{}'),
 		       t.id, t.title, t.slug, t.description, t.created_at
 		FROM labs l
 		JOIN topics t ON l.topic_id = t.id
-		WHERE t.slug = $1
+		LEFT JOIN users au ON au.id = l.author_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLab(row rowScanner) (*models.Lab, error) {
+	var l models.Lab
+	var t models.Topic
+	var expected, variables []byte
+	var destructive []int64
+	err := row.Scan(
+		&l.ID, &l.TopicID, &l.Title, &l.Slug, &l.Content, pq.Array(&l.Commands), &l.Difficulty, &l.CreatedAt, &l.UpdatedAt, &l.DeletedAt, &l.Status,
+		&l.PublishAt, &l.UnpublishAt, &l.AuthorID, &l.Author,
+		&l.Rating, &l.RatingCount,
+		pq.Array(&l.Tags), pq.Array(&l.Requires),
+		&expected, &l.Outdated, &variables, pq.Array(&destructive),
+		&t.ID, &t.Title, &t.Slug, &t.Description, &t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(expected) > 0 {
+		if err := json.Unmarshal(expected, &l.Expected); err != nil {
+			return nil, err
+		}
+	}
+	if len(variables) > 0 {
+		if err := json.Unmarshal(variables, &l.Variables); err != nil {
+			return nil, err
+		}
+	}
+	for _, step := range destructive {
+		l.DestructiveSteps = append(l.DestructiveSteps, int(step))
+	}
+	l.Topic = &t
+	return &l, nil
+}
+
+// GetLabsByTopicSlug возвращает лабы темы; publishedOnly скрывает черновики и архив
+func (db *DB) GetLabsByTopicSlug(topicSlug string, publishedOnly bool) ([]models.Lab, error) {
+	query := labSelect + `
+		WHERE t.slug = $1 AND l.deleted_at IS NULL AND (NOT $2 OR l.status = 'published')
 		ORDER BY l.created_at DESC`
 
-	rows, err := db.Query(query, topicSlug)
+	rows, err := db.Query(query, topicSlug, publishedOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -139,83 +418,214 @@ func (db *DB) GetLabsByTopicSlug(topicSlug string) ([]models.Lab, error) {
 
 	var labs []models.Lab
 	for rows.Next() {
-		var l models.Lab
-		var t models.Topic
-		err := rows.Scan(
-			&l.ID, &l.TopicID, &l.Title, &l.Slug, &l.Content, pq.Array(&l.Commands), &l.Difficulty, &l.CreatedAt, &l.UpdatedAt,
-			&t.ID, &t.Title, &t.Slug, &t.Description, &t.CreatedAt,
-		)
+		l, err := scanLab(rows)
 		if err != nil {
 			return nil, err
 		}
-		l.Topic = &t
-		labs = append(labs, l)
+		labs = append(labs, *l)
 	}
 	return labs, nil
 }
 
-func (db *DB) GetLabBySlug(topicSlug, labSlug string) (*models.Lab, error) {
-	query := `
-		SELECT l.id, l.topic_id, l.title, l.slug, l.content, l.commands, l.difficulty, l.created_at, l.updated_at,
-		       t.id, t.title, t.slug, t.description, t.created_at
-		FROM labs l
-		JOIN topics t ON l.topic_id = t.id
-		WHERE t.slug = $1 AND l.slug = $2`
+func (db *DB) GetLabBySlug(topicSlug, labSlug string, publishedOnly bool) (*models.Lab, error) {
+	return scanLab(db.QueryRow(labSelect+`
+		WHERE t.slug = $1 AND l.slug = $2 AND l.deleted_at IS NULL AND (NOT $3 OR l.status = 'published')`,
+		topicSlug, labSlug, publishedOnly))
+}
 
-	var l models.Lab
-	var t models.Topic
-	err := db.QueryRow(query, topicSlug, labSlug).Scan(
-		&l.ID, &l.TopicID, &l.Title, &l.Slug, &l.Content, pq.Array(&l.Commands), &l.Difficulty, &l.CreatedAt, &l.UpdatedAt,
-		&t.ID, &t.Title, &t.Slug, &t.Description, &t.CreatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	l.Topic = &t
-	return &l, nil
+func (db *DB) GetLabByID(id int) (*models.Lab, error) {
+	return scanLab(db.QueryRow(labSelect+`
+		WHERE l.id = $1 AND l.deleted_at IS NULL`, id))
 }
 
+// CreateLab создаёт лабу-черновик вместе с её тегами и пререквизитами
 func (db *DB) CreateLab(lab *models.Lab) error {
 	// Генерируем slug из названия
 	lab.Slug = slugify(lab.Title)
 	
-	query := `INSERT INTO labs (topic_id, title, slug, content, commands, difficulty) 
-	          VALUES ($1, $2, $3, $4, $5, $6) 
-	          RETURNING id, created_at, updated_at`
+	lab.Status = models.LabStatusDraft
 	
-	err := db.QueryRow(query, lab.TopicID, lab.Title, lab.Slug, lab.Content,
-		pq.Array(lab.Commands), lab.Difficulty).Scan(&lab.ID, &lab.CreatedAt, &lab.UpdatedAt)
+	query := `INSERT INTO labs (topic_id, title, slug, content, commands, difficulty, status, publish_at, unpublish_at, author_id, expectations, variables, destructive_steps) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0), $11, $12, $13) 
+	          RETURNING id, created_at, updated_at`
 	
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(query, lab.TopicID, lab.Title, lab.Slug, lab.Content,
+		pq.Array(lab.Commands), lab.Difficulty, lab.Status, lab.PublishAt, lab.UnpublishAt, lab.AuthorID,
+		jsonOrNil(lab.Expected), jsonOrNil(lab.Variables), stepsOrNil(lab.DestructiveSteps)).Scan(&lab.ID, &lab.CreatedAt, &lab.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return commitLabRelations(tx, lab)
 }
 
+// UpdateLab сохраняет правку лабы; Expected, Variables и DestructiveSteps == nil
+// оставляют прежние значения, как и nil-даты расписания без ClearSchedule и
+// пустой Status. Requires и Tags, если не nil, заменяются в той же транзакции
 func (db *DB) UpdateLab(lab *models.Lab) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateLab(tx, lab); err != nil {
+		return err
+	}
+	return commitLabRelations(tx, lab)
+}
+
+// updateLab - UPDATE полей лабы для UpdateLab и ApplyLabRevision
+func updateLab(tx *sql.Tx, lab *models.Lab) error {
 	query := `UPDATE labs 
-	          SET title = $1, content = $2, commands = $3, difficulty = $4, updated_at = NOW()
-	          WHERE id = $5`
-	
-	_, err := db.Exec(query, lab.Title, lab.Content, pq.Array(lab.Commands), lab.Difficulty, lab.ID)
+	          SET title = $1, content = $2, commands = $3, difficulty = $4,
+	              status = COALESCE(NULLIF($12, ''), status),
+	              publish_at = CASE WHEN $11 THEN $6::timestamptz ELSE COALESCE($6::timestamptz, publish_at) END,
+	              unpublish_at = CASE WHEN $11 THEN $7::timestamptz ELSE COALESCE($7::timestamptz, unpublish_at) END,
+	              expectations = COALESCE($8, expectations),
+	              variables = COALESCE($9, variables),
+	              destructive_steps = COALESCE($10, destructive_steps), updated_at = NOW()
+	          WHERE id = $5 AND deleted_at IS NULL`
+
+	_, err := tx.Exec(query, lab.Title, lab.Content, pq.Array(lab.Commands), lab.Difficulty, lab.ID,
+		lab.PublishAt, lab.UnpublishAt, jsonOrNil(lab.Expected), jsonOrNil(lab.Variables),
+		stepsOrNil(lab.DestructiveSteps), lab.ClearSchedule, lab.Status)
 	return err
 }
 
+// commitLabRelations заменяет пререквизиты и теги лабы (если не nil) и фиксирует
+// транзакцию: цикл зависимостей откатывает всю правку, а не оставляет лабу
+// наполовину сохранённой
+func commitLabRelations(tx *sql.Tx, lab *models.Lab) error {
+	if lab.Requires != nil {
+		if err := setLabPrerequisites(tx, lab); err != nil {
+			return err
+		}
+	}
+	var slugs []string
+	if lab.Tags != nil {
+		var err error
+		if slugs, err = setLabTags(tx, lab); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if lab.Tags != nil {
+		lab.Tags = slugs
+	}
+	return nil
+}
+
+// stepsOrNil - значение labs.destructive_steps: nil-срез пишется как NULL
+func stepsOrNil(steps []int) interface{} {
+	if steps == nil {
+		return nil
+	}
+	arr := make(pq.Int64Array, len(steps))
+	for i, s := range steps {
+		arr[i] = int64(s)
+	}
+	return arr
+}
+
+// jsonOrNil - значение JSONB колонки лабы: nil-срез пишется как NULL
+func jsonOrNil(v interface{}) interface{} {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		return nil
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// DeleteLab перемещает лабу в корзину (мягкое удаление)
 func (db *DB) DeleteLab(id int) error {
-	_, err := db.Exec("DELETE FROM labs WHERE id = $1", id)
+	_, err := db.Exec("UPDATE labs SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
 	return err
 }
 
 // Auth methods
-func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+const userColumns = "id, username, password_hash, is_admin, role, COALESCE(oidc_subject, ''), COALESCE(email, '')"
+
+func scanUser(row *sql.Row) (*models.User, error) {
 	var u models.User
-	err := db.QueryRow("SELECT id, username, password_hash, is_admin FROM users WHERE username = $1", username).
-		Scan(&u.ID, &u.Username, &u.Password, &u.IsAdmin)
+	err := row.Scan(&u.ID, &u.Username, &u.Password, &u.IsAdmin, &u.Role, &u.OIDCSubject, &u.Email)
 	if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
+func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+	return scanUser(db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = $1", username))
+}
+
+func (db *DB) GetUserByID(id int) (*models.User, error) {
+	return scanUser(db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+// ErrOIDCUsernameTaken - все варианты username для нового OIDC пользователя заняты
+var ErrOIDCUsernameTaken = errors.New("no free username for OIDC user")
+
+// UpsertOIDCUser находит пользователя по subject из ID токена или создаёт его (JIT).
+// Роль синхронизируется с группами IdP при каждом входе.
+// Локальные учётки с тем же username не связываются автоматически: новый
+// пользователь получает первый свободный вариант из oidcUsernames.
+// Второй результат - true, если пользователь был создан.
+func (db *DB) UpsertOIDCUser(subject, username, role string) (*models.User, bool, error) {
+	isAdmin := role == models.RoleAdmin
+
+	update := "UPDATE users SET role = $2, is_admin = $3 WHERE oidc_subject = $1 RETURNING " + userColumns
+	// Пароль "!" не является bcrypt хешем, вход по паролю для такой учётки невозможен.
+	// DO NOTHING без цели покрывает и username, и oidc_subject: при одновременном
+	// первом входе с тем же subject вставка ничего не вернёт, и пользователя,
+	// созданного параллельным запросом, найдёт UPDATE на следующей итерации
+	insert := `INSERT INTO users (username, password_hash, is_admin, role, oidc_subject)
+	           VALUES ($1, '!', $2, $3, $4)
+	           ON CONFLICT DO NOTHING
+	           RETURNING ` + userColumns
+	candidates := oidcUsernames(username, subject)
+	for _, name := range candidates {
+		u, err := scanUser(db.QueryRow(update, subject, role, isAdmin))
+		if err != sql.ErrNoRows {
+			return u, false, err
+		}
+		u, err = scanUser(db.QueryRow(insert, name, isAdmin, role, subject))
+		if err != sql.ErrNoRows {
+			return u, err == nil, err
+		}
+	}
+	return nil, false, fmt.Errorf("%w: %s..%s", ErrOIDCUsernameTaken, candidates[0], candidates[len(candidates)-1])
+}
+
+// oidcUsernamesMax - сколько вариантов username перебирает UpsertOIDCUser
+const oidcUsernamesMax = 10
+
+// oidcUsernames перечисляет варианты username для нового OIDC пользователя:
+// сам username, затем с суффиксом из subject и порядковым номером
+func oidcUsernames(username, subject string) []string {
+	suffix := subject
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	names := []string{username, username + "-" + suffix}
+	for i := 2; len(names) < oidcUsernamesMax; i++ {
+		names = append(names, fmt.Sprintf("%s-%s-%d", username, suffix, i))
+	}
+	return names
+}
+
 func (db *DB) CreateSession(userID int) string {
 	token := generateToken()
+	db.mu.Lock()
+	defer db.mu.Unlock()
 	db.sessions[token] = &models.Session{
 		Token:     token,
 		UserID:    userID,
@@ -225,6 +635,8 @@ func (db *DB) CreateSession(userID int) string {
 }
 
 func (db *DB) GetSession(token string) *models.Session {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 	session, exists := db.sessions[token]
 	if !exists || session.ExpiresAt.Before(time.Now()) {
 		return nil
@@ -233,38 +645,109 @@ func (db *DB) GetSession(token string) *models.Session {
 }
 
 func (db *DB) DeleteSession(token string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
 	delete(db.sessions, token)
 }
 
 func (db *DB) CreateUser(username, password string, isAdmin bool) error {
+	role := models.RoleReader
+	if isAdmin {
+		role = models.RoleAdmin
+	}
+	_, err := insertUser(db, username, "", password, role)
+	return err
+}
+
+// SetPassword заменяет пароль пользователя
+func (db *DB) SetPassword(userID int, password string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
-	
-	_, err = db.Exec("INSERT INTO users (username, password_hash, is_admin) VALUES ($1, $2, $3)",
-		username, string(hash), isAdmin)
+	_, err = db.Exec("UPDATE users SET password_hash = $2 WHERE id = $1", userID, string(hash))
 	return err
 }
 
+// GetAdminsWithPassword возвращает админов, чей пароль совпадает с одним из
+// passwords. bcrypt медленный, поэтому проверяются только админы.
+func (db *DB) GetAdminsWithPassword(passwords []string) ([]string, error) {
+	rows, err := db.Query("SELECT username, password_hash FROM users WHERE role = $1 ORDER BY username", models.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username, hash string
+		if err := rows.Scan(&username, &hash); err != nil {
+			return nil, err
+		}
+		for _, p := range passwords {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(p)) == nil {
+				usernames = append(usernames, username)
+				break
+			}
+		}
+	}
+	return usernames, rows.Err()
+}
+
+// queryRower - общий интерфейс *sql.DB и *sql.Tx для запросов с одной строкой
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertUser хеширует пароль и создаёт пользователя; пустой email хранится как NULL
+func insertUser(q queryRower, username, email, password, role string) (*models.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanUser(q.QueryRow(`INSERT INTO users (username, email, password_hash, is_admin, role)
+	                            VALUES ($1, NULLIF($2, ''), $3, $4, $5) RETURNING `+userColumns,
+		username, email, string(hash), role == models.RoleAdmin, role))
+}
+
 // Helpers
+
+// IsUniqueViolation - ошибка нарушения уникального ограничения Postgres
+func IsUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// translit - упрощённая транслитерация кириллицы для slug'ов
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// slugify - пробелы в дефисы, нижний регистр, кириллица латиницей;
+// остальные символы и крайние дефисы отбрасываются, поэтому результат может быть пустым
 func slugify(s string) string {
-	// Простая реализация - заменить пробелы на дефисы и привести к нижнему регистру
-	// В продакшене лучше использовать библиотеку
-	result := ""
-	for _, r := range s {
-		if r == ' ' {
-			result += "-"
-		} else if r >= 'A' && r <= 'Z' {
-			result += string(r + 32)
-		} else if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
-			result += string(r)
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == ' ' || r == '-':
+			b.WriteByte('-')
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		default:
+			b.WriteString(translit[r])
 		}
 	}
-	return result
+	return strings.Trim(b.String(), "-")
 }
 
+// generateToken - 256 случайных бит; rand.Read при сбое сам завершает процесс
 func generateToken() string {
-	// Простая генерация токена
-	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), time.Now().Unix())
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
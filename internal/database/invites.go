@@ -0,0 +1,106 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"devops-manual/internal/models"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrInvalidInvite - инвайт-код не существует, уже использован или истёк
+var ErrInvalidInvite = errors.New("invalid or used invite code")
+
+// RegisterUser создаёт читателя при саморегистрации. Непустой invite
+// погашается в той же транзакции, поэтому один код нельзя использовать дважды.
+func (db *DB) RegisterUser(username, email, password, invite string) (*models.User, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	u, err := insertUser(tx, username, email, password, models.RoleReader)
+	if err != nil {
+		return nil, err
+	}
+
+	if invite != "" {
+		res, err := tx.Exec(`UPDATE invite_codes SET used_by = $2, used_at = NOW()
+		                     WHERE code = $1 AND used_by IS NULL AND used_at IS NULL
+		                       AND (expires_at IS NULL OR expires_at > NOW())`, invite, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, ErrInvalidInvite
+		}
+	}
+
+	return u, tx.Commit()
+}
+
+const inviteColumns = "code, COALESCE(created_by, 0), created_at, expires_at, used_by, used_at"
+
+func scanInvite(row rowScanner) (*models.InviteCode, error) {
+	var inv models.InviteCode
+	var usedBy sql.NullInt64
+	var expiresAt, usedAt sql.NullTime
+	if err := row.Scan(&inv.Code, &inv.CreatedBy, &inv.CreatedAt, &expiresAt, &usedBy, &usedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		inv.ExpiresAt = &expiresAt.Time
+	}
+	if usedBy.Valid {
+		id := int(usedBy.Int64)
+		inv.UsedBy = &id
+	}
+	if usedAt.Valid {
+		inv.UsedAt = &usedAt.Time
+	}
+	return &inv, nil
+}
+
+// CreateInviteCode выпускает новый код; expiresAt == nil - бессрочный
+func (db *DB) CreateInviteCode(createdBy int, expiresAt *time.Time) (*models.InviteCode, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return scanInvite(db.QueryRow(`INSERT INTO invite_codes (code, created_by, expires_at)
+	                               VALUES ($1, NULLIF($2, 0), $3) RETURNING `+inviteColumns,
+		hex.EncodeToString(b), createdBy, expiresAt))
+}
+
+// GetInviteCodes возвращает все коды, новые сверху
+func (db *DB) GetInviteCodes() ([]models.InviteCode, error) {
+	rows, err := db.Query("SELECT " + inviteColumns + " FROM invite_codes ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.InviteCode{}
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *inv)
+	}
+	return list, rows.Err()
+}
+
+// DeleteInviteCode отзывает неиспользованный код
+func (db *DB) DeleteInviteCode(code string) error {
+	res, err := db.Exec("DELETE FROM invite_codes WHERE code = $1 AND used_at IS NULL", code)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"devops-manual/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// labSort описывает поле сортировки: SQL выражение, приведение типа
+// значения курсора и получение значения из лабы для следующего курсора
+type labSort struct {
+	expr  string
+	cast  string
+	value func(l *models.Lab) string
+}
+
+var labSorts = map[string]labSort{
+	"title": {
+		expr:  "l.title",
+		cast:  "text",
+		value: func(l *models.Lab) string { return l.Title },
+	},
+	"created_at": {
+		expr:  "l.created_at",
+		cast:  "timestamp",
+		value: func(l *models.Lab) string { return l.CreatedAt.Format(time.RFC3339Nano) },
+	},
+	"updated_at": {
+		expr:  "l.updated_at",
+		cast:  "timestamp",
+		value: func(l *models.Lab) string { return l.UpdatedAt.Format(time.RFC3339Nano) },
+	},
+	"rating": {
+		expr:  ratingAvgExpr,
+		cast:  "numeric",
+		value: func(l *models.Lab) string { return strconv.FormatFloat(l.Rating, 'f', 2, 64) },
+	},
+	"difficulty": {
+		expr:  "CASE l.difficulty WHEN 'easy' THEN 1 WHEN 'medium' THEN 2 WHEN 'hard' THEN 3 ELSE 4 END",
+		cast:  "int",
+		value: func(l *models.Lab) string { return strconv.Itoa(DifficultyRank(l.Difficulty)) },
+	},
+}
+
+// DifficultyRank - порядок сложности для сортировки, совпадает с SQL выражением
+func DifficultyRank(d string) int {
+	switch d {
+	case "easy":
+		return 1
+	case "medium":
+		return 2
+	case "hard":
+		return 3
+	}
+	return 4
+}
+
+// IsValidLabSort проверяет, что поле сортировки поддерживается
+func IsValidLabSort(field string) bool {
+	_, ok := labSorts[field]
+	return ok
+}
+
+// labCursor - позиция в выдаче: значение поля сортировки и id последней лабы.
+// Sort и Desc - сортировка, для которой выдан курсор: значение другого поля
+// не сравнить с текущим
+type labCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeCursor(c labCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ErrInvalidCursor - курсор повреждён или получен не от ListLabs
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// decodeCursor разбирает курсор и проверяет, что он выдан для той же сортировки
+func decodeCursor(s, sort string, desc bool) (*labCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c labCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Desc != desc {
+		return nil, fmt.Errorf("%w: issued for a different sort", ErrInvalidCursor)
+	}
+	return &c, nil
+}
+
+// ListLabs возвращает страницу лаб (keyset пагинация), курсор следующей
+// страницы (пустой на последней) и общее число лаб под фильтрами
+func (db *DB) ListLabs(q models.LabQuery) ([]models.Lab, string, int, error) {
+	if q.Sort == "" {
+		q.Sort = "created_at"
+	}
+	sort, ok := labSorts[q.Sort]
+	if !ok {
+		return nil, "", 0, fmt.Errorf("unsupported sort field %q", q.Sort)
+	}
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+
+	conds := []string{"l.deleted_at IS NULL"}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.PublishedOnly {
+		conds = append(conds, "l.status = 'published'")
+	}
+	if q.TopicSlug != "" {
+		conds = append(conds, "t.slug = "+arg(q.TopicSlug))
+	}
+	if q.Difficulty != "" {
+		conds = append(conds, "l.difficulty = "+arg(q.Difficulty))
+	}
+	if q.Author != "" {
+		conds = append(conds, "au.username = "+arg(q.Author))
+	}
+	if q.Tag != "" {
+		conds = append(conds, `EXISTS (SELECT 1 FROM lab_tags lt JOIN tags tg ON tg.id = lt.tag_id
+			WHERE lt.lab_id = l.id AND tg.slug = `+arg(q.Tag)+`)`)
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM labs l
+		JOIN topics t ON l.topic_id = t.id
+		LEFT JOIN users au ON au.id = l.author_id
+		WHERE ` + strings.Join(conds, " AND ")
+	if err := db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, "", 0, err
+	}
+
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		cur, err := decodeCursor(q.Cursor, q.Sort, q.Desc)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		conds = append(conds, fmt.Sprintf("(%s, l.id) %s (%s::%s, %s)",
+			sort.expr, cmp, arg(cur.Value), sort.cast, arg(cur.ID)))
+	}
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница
+	query := fmt.Sprintf("%s WHERE %s ORDER BY %s %s, l.id %s LIMIT %d",
+		labSelect, strings.Join(conds, " AND "), sort.expr, dir, dir, q.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer rows.Close()
+
+	labs := []models.Lab{}
+	for rows.Next() {
+		l, err := scanLab(rows)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		labs = append(labs, *l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", 0, err
+	}
+
+	next := ""
+	if len(labs) > q.Limit {
+		labs = labs[:q.Limit]
+		last := &labs[len(labs)-1]
+		next = encodeCursor(labCursor{Sort: q.Sort, Desc: q.Desc, Value: sort.value(last), ID: last.ID})
+	}
+	return labs, next, total, nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	cursor := encodeCursor(labCursor{Sort: "rating", Desc: true, Value: "4.50", ID: 12})
+
+	c, err := decodeCursor(cursor, "rating", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Value != "4.50" || c.ID != 12 {
+		t.Errorf("decoded %+v", c)
+	}
+
+	// Курсор другой сортировки ушёл бы в SQL с чужим типом значения
+	for _, tt := range []struct {
+		sort string
+		desc bool
+	}{{"created_at", true}, {"rating", false}} {
+		if _, err := decodeCursor(cursor, tt.sort, tt.desc); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("sort %s desc %v: err = %v, want ErrInvalidCursor", tt.sort, tt.desc, err)
+		}
+	}
+	for _, bad := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := decodeCursor(bad, "rating", true); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q) = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
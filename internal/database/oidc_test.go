@@ -0,0 +1,28 @@
+package database
+
+import "testing"
+
+func TestOIDCUsernames(t *testing.T) {
+	names := oidcUsernames("ivan", "0123456789abcdef")
+	if len(names) != oidcUsernamesMax {
+		t.Fatalf("got %d candidates, want %d", len(names), oidcUsernamesMax)
+	}
+	want := []string{"ivan", "ivan-01234567", "ivan-01234567-2", "ivan-01234567-3"}
+	for i, w := range want {
+		if names[i] != w {
+			t.Errorf("names[%d] = %q, want %q", i, names[i], w)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if seen[n] {
+			t.Errorf("duplicate candidate %q", n)
+		}
+		seen[n] = true
+	}
+
+	if short := oidcUsernames("ivan", "42"); short[1] != "ivan-42" {
+		t.Errorf("short subject: %q, want ivan-42", short[1])
+	}
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrPrerequisiteCycle - пререквизиты образуют цикл
+	ErrPrerequisiteCycle = errors.New("prerequisites form a cycle")
+	// ErrUnknownLab - ссылка на несуществующую лабу
+	ErrUnknownLab = errors.New("unknown lab")
+)
+
+// setLabPrerequisites заменяет пререквизиты лабы ссылками из lab.Requires
+// ("topic/lab") и отклоняет изменения, создающие цикл зависимостей
+func setLabPrerequisites(tx *sql.Tx, lab *models.Lab) error {
+	if _, err := tx.Exec("DELETE FROM lab_prerequisites WHERE lab_id = $1", lab.ID); err != nil {
+		return err
+	}
+
+	for _, ref := range lab.Requires {
+		topicSlug, labSlug, ok := strings.Cut(strings.TrimSpace(ref), "/")
+		if !ok {
+			return fmt.Errorf("%w: %q (expected topic/lab)", ErrUnknownLab, ref)
+		}
+
+		var requiresID int
+		err := tx.QueryRow(`SELECT l.id FROM labs l JOIN topics t ON t.id = l.topic_id
+		                    WHERE t.slug = $1 AND l.slug = $2 AND l.deleted_at IS NULL`,
+			topicSlug, labSlug).Scan(&requiresID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %q", ErrUnknownLab, ref)
+		}
+		if err != nil {
+			return err
+		}
+		if requiresID == lab.ID {
+			return ErrPrerequisiteCycle
+		}
+
+		// Цикл возникает, если требуемая лаба (транзитивно) уже зависит от текущей
+		var cycle bool
+		err = tx.QueryRow(`
+			WITH RECURSIVE deps(id) AS (
+				SELECT requires_id FROM lab_prerequisites WHERE lab_id = $1
+				UNION
+				SELECT lp.requires_id FROM lab_prerequisites lp JOIN deps d ON lp.lab_id = d.id
+			)
+			SELECT EXISTS (SELECT 1 FROM deps WHERE id = $2)`, requiresID, lab.ID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("%w: %s requires this lab", ErrPrerequisiteCycle, ref)
+		}
+
+		if _, err := tx.Exec(`INSERT INTO lab_prerequisites (lab_id, requires_id) VALUES ($1, $2)
+		                      ON CONFLICT DO NOTHING`, lab.ID, requiresID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) GetPaths() ([]models.Path, error) {
+	rows, err := db.Query("SELECT id, title, slug, description, created_at, updated_at FROM paths ORDER BY title")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := []models.Path{}
+	for rows.Next() {
+		var p models.Path
+		if err := rows.Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// GetPathBySlug возвращает маршрут с элементами; удалённые лабы пропускаются,
+// publishedOnly дополнительно скрывает неопубликованные
+func (db *DB) GetPathBySlug(slug string, publishedOnly bool) (*models.Path, error) {
+	return db.getPath("slug = $1", slug, publishedOnly)
+}
+
+func (db *DB) GetPathByID(id int) (*models.Path, error) {
+	return db.getPath("id = $1", id, false)
+}
+
+func (db *DB) getPath(cond string, arg interface{}, publishedOnly bool) (*models.Path, error) {
+	var p models.Path
+	err := db.QueryRow("SELECT id, title, slug, description, created_at, updated_at FROM paths WHERE "+cond, arg).
+		Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT COALESCE(lab_id, 0), section FROM path_items WHERE path_id = $1 ORDER BY position", p.ID)
+	if err != nil {
+		return nil, err
+	}
+	var items []models.PathItem
+	for rows.Next() {
+		var item models.PathItem
+		if err := rows.Scan(&item.LabID, &item.Section); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	p.Items = []models.PathItem{}
+	for _, item := range items {
+		if item.LabID != 0 {
+			lab, err := db.GetLabByID(item.LabID)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if publishedOnly && lab.Status != models.LabStatusPublished {
+				continue
+			}
+			item.Lab = lab
+		}
+		p.Items = append(p.Items, item)
+	}
+	return &p, nil
+}
+
+func (db *DB) CreatePath(p *models.Path) error {
+	p.Slug = slugify(p.Title)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO paths (title, slug, description) VALUES ($1, $2, $3)
+	                   RETURNING id, created_at, updated_at`,
+		p.Title, p.Slug, p.Description).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := insertPathItems(tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePath обновляет маршрут и полностью заменяет его элементы
+func (db *DB) UpdatePath(p *models.Path) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE paths SET title = $1, description = $2, updated_at = NOW() WHERE id = $3",
+		p.Title, p.Description, p.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec("DELETE FROM path_items WHERE path_id = $1", p.ID); err != nil {
+		return err
+	}
+	if err := insertPathItems(tx, p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeletePath(id int) error {
+	res, err := db.Exec("DELETE FROM paths WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func insertPathItems(tx *sql.Tx, p *models.Path) error {
+	for i, item := range p.Items {
+		if item.LabID == 0 && item.Section == "" {
+			return fmt.Errorf("path item %d: lab_id or section is required", i)
+		}
+
+		var labID interface{}
+		if item.LabID != 0 {
+			var exists bool
+			err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM labs WHERE id = $1 AND deleted_at IS NULL)", item.LabID).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%w: id %d", ErrUnknownLab, item.LabID)
+			}
+			labID = item.LabID
+		}
+
+		if _, err := tx.Exec("INSERT INTO path_items (path_id, position, lab_id, section) VALUES ($1, $2, $3, $4)",
+			p.ID, i, labID, item.Section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"devops-manual/internal/models"
+)
+
+// stepKey переводит необязательный шаг в значение колонки step
+func stepKey(step *int) int {
+	if step == nil {
+		return -1
+	}
+	return *step
+}
+
+// MarkProgress отмечает лабу или её шаг пройденным (повторная отметка не меняет время)
+func (db *DB) MarkProgress(userID, labID int, step *int) error {
+	_, err := db.Exec(`INSERT INTO progress (user_id, lab_id, step) VALUES ($1, $2, $3)
+	                   ON CONFLICT DO NOTHING`, userID, labID, stepKey(step))
+	return err
+}
+
+// UnmarkProgress снимает отметку с лабы или шага
+func (db *DB) UnmarkProgress(userID, labID int, step *int) error {
+	_, err := db.Exec("DELETE FROM progress WHERE user_id = $1 AND lab_id = $2 AND step = $3",
+		userID, labID, stepKey(step))
+	return err
+}
+
+// GetUserProgress возвращает все отметки пользователя; labID != 0 ограничивает одной лабой
+func (db *DB) GetUserProgress(userID, labID int) ([]models.Progress, error) {
+	rows, err := db.Query(`SELECT lab_id, step, completed_at FROM progress
+	                       WHERE user_id = $1 AND ($2 = 0 OR lab_id = $2)
+	                       ORDER BY lab_id, step`, userID, labID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Progress{}
+	for rows.Next() {
+		var p models.Progress
+		var step int
+		if err := rows.Scan(&p.LabID, &step, &p.CompletedAt); err != nil {
+			return nil, err
+		}
+		if step >= 0 {
+			s := step
+			p.Step = &s
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// progressByTopic - пройденные (целиком) опубликованные лабы против общего числа по темам.
+// userID = 0 строит отчёт по всем пользователям.
+const progressByTopic = `
+	SELECT u.id, u.username, t.slug, t.title,
+	       COUNT(p.lab_id), COUNT(l.id)
+	FROM users u
+	CROSS JOIN topics t
+	JOIN labs l ON l.topic_id = t.id AND l.deleted_at IS NULL AND l.status = 'published'
+	LEFT JOIN progress p ON p.user_id = u.id AND p.lab_id = l.id AND p.step = -1
+	WHERE ($1 = 0 OR u.id = $1)
+	GROUP BY u.id, u.username, t.slug, t.title
+	ORDER BY u.username, t.title`
+
+// GetTopicProgress возвращает процент прохождения по темам; userID = 0 - по всем пользователям
+func (db *DB) GetTopicProgress(userID int) ([]models.TopicProgress, error) {
+	rows, err := db.Query(progressByTopic, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.TopicProgress{}
+	for rows.Next() {
+		var tp models.TopicProgress
+		if err := rows.Scan(&tp.UserID, &tp.Username, &tp.TopicSlug, &tp.TopicTitle, &tp.Completed, &tp.Total); err != nil {
+			return nil, err
+		}
+		if tp.Total > 0 {
+			tp.Percent = float64(tp.Completed) * 100 / float64(tp.Total)
+		}
+		list = append(list, tp)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/models"
+	"time"
+)
+
+// RateLab ставит или меняет оценку пользователя
+func (db *DB) RateLab(userID, labID, score int) error {
+	_, err := db.Exec(`INSERT INTO lab_ratings (user_id, lab_id, score) VALUES ($1, $2, $3)
+	                   ON CONFLICT (user_id, lab_id) DO UPDATE SET score = EXCLUDED.score, updated_at = NOW()`,
+		userID, labID, score)
+	return err
+}
+
+// UnrateLab снимает оценку пользователя
+func (db *DB) UnrateLab(userID, labID int) error {
+	_, err := db.Exec("DELETE FROM lab_ratings WHERE user_id = $1 AND lab_id = $2", userID, labID)
+	return err
+}
+
+// GetUserRating возвращает оценку пользователя, 0 - не оценивал
+func (db *DB) GetUserRating(userID, labID int) (int, error) {
+	var score int
+	err := db.QueryRow("SELECT score FROM lab_ratings WHERE user_id = $1 AND lab_id = $2", userID, labID).Scan(&score)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return score, err
+}
+
+// GetTopRatedLabs - опубликованные лабы с лучшей средней оценкой среди оценок,
+// поставленных или изменённых после since
+func (db *DB) GetTopRatedLabs(since time.Time, limit int) ([]models.Lab, error) {
+	rows, err := db.Query(labSelect+`
+		JOIN (SELECT lab_id, AVG(score) AS avg, COUNT(*) AS cnt FROM lab_ratings
+		      WHERE updated_at >= $1 GROUP BY lab_id) recent ON recent.lab_id = l.id
+		WHERE l.deleted_at IS NULL AND l.status = 'published'
+		ORDER BY recent.avg DESC, recent.cnt DESC, l.id
+		LIMIT $2`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labs := []models.Lab{}
+	for rows.Next() {
+		l, err := scanLab(rows)
+		if err != nil {
+			return nil, err
+		}
+		labs = append(labs, *l)
+	}
+	return labs, rows.Err()
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownRevision - событие аудита не является правкой этой лабы
+var ErrUnknownRevision = errors.New("audit event is not an update of this lab")
+
+const reportSelect = `
+	SELECT r.id, r.lab_id, l.title, COALESCE(r.user_id, 0), COALESCE(u.username, ''),
+	       r.type, r.description, r.environment, r.status, r.fixed_by_event_id,
+	       r.created_at, r.updated_at
+	FROM lab_reports r
+	JOIN labs l ON l.id = r.lab_id
+	LEFT JOIN users u ON u.id = r.user_id`
+
+func scanReport(row rowScanner) (*models.Report, error) {
+	var r models.Report
+	var fixedBy sql.NullInt64
+	err := row.Scan(&r.ID, &r.LabID, &r.LabTitle, &r.UserID, &r.Reporter,
+		&r.Type, &r.Description, &r.Environment, &r.Status, &fixedBy,
+		&r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if fixedBy.Valid {
+		r.FixedByEventID = &fixedBy.Int64
+	}
+	return &r, nil
+}
+
+func (db *DB) CreateReport(r *models.Report) error {
+	var id int
+	err := db.QueryRow(`INSERT INTO lab_reports (lab_id, user_id, type, description, environment)
+	                    VALUES ($1, NULLIF($2, 0), $3, $4, $5) RETURNING id`,
+		r.LabID, r.UserID, r.Type, r.Description, r.Environment).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	created, err := db.GetReportByID(id)
+	if err != nil {
+		return err
+	}
+	*r = *created
+	return nil
+}
+
+func (db *DB) GetReportByID(id int) (*models.Report, error) {
+	return scanReport(db.QueryRow(reportSelect+" WHERE r.id = $1", id))
+}
+
+// GetReports возвращает страницу сообщений под фильтром (новые сверху) и их общее число
+func (db *DB) GetReports(f models.ReportFilter) ([]models.Report, int, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.LabID != 0 {
+		add("r.lab_id = $%d", f.LabID)
+	}
+	if f.Status != "" {
+		add("r.status = $%d", f.Status)
+	}
+	if f.Type != "" {
+		add("r.type = $%d", f.Type)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM lab_reports r"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf("%s%s ORDER BY r.created_at DESC, r.id DESC LIMIT %d OFFSET %d",
+		reportSelect, where, PageLimit(f.Limit), f.Offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reports = append(reports, *r)
+	}
+	return reports, total, rows.Err()
+}
+
+// UpdateReport сохраняет статус и ссылку на исправляющую правку.
+// Ссылка принимается только на событие lab.update той же лабы.
+func (db *DB) UpdateReport(r *models.Report) error {
+	if r.FixedByEventID != nil {
+		var ok bool
+		err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM audit_events
+		                    WHERE id = $1 AND action = 'lab.update' AND target_type = 'lab' AND target_id = $2)`,
+			*r.FixedByEventID, strconv.Itoa(r.LabID)).Scan(&ok)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrUnknownRevision
+		}
+	}
+
+	res, err := db.Exec(`UPDATE lab_reports SET status = $2, fixed_by_event_id = $3, updated_at = NOW()
+	                     WHERE id = $1`, r.ID, r.Status, r.FixedByEventID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountOpenReports - число нерешённых сообщений (open и acknowledged) по лабе
+func (db *DB) CountOpenReports(labID int) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM lab_reports WHERE lab_id = $1 AND status IN ('open', 'acknowledged')",
+		labID).Scan(&n)
+	return n, err
+}
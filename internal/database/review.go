@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/models"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// SetLabStatus переводит лабу в статус to, только если текущий статус входит в from.
+// sql.ErrNoRows означает, что переход из текущего статуса недопустим.
+// Ожидающая правка сохраняется, только пока лаба опубликована или запланирована.
+func (db *DB) SetLabStatus(id int, to string, from ...string) error {
+	res, err := db.Exec(`UPDATE labs SET status = $2, updated_at = NOW(),
+	                            pending_revision = CASE WHEN $2 IN ('published', 'scheduled') THEN pending_revision END
+	                     WHERE id = $1 AND deleted_at IS NULL AND status = ANY($3)`,
+		id, to, pq.Array(from))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetLabRevision сохраняет правку опубликованной лабы до ревью, заменяя прежнюю;
+// nil удаляет правку. rev должна быть полной: ApplyLabRevision записывает её как есть.
+func (db *DB) SetLabRevision(id int, rev *models.Lab) error {
+	var data interface{}
+	if rev != nil {
+		b, err := json.Marshal(rev)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	res, err := db.Exec("UPDATE labs SET pending_revision = $2 WHERE id = $1 AND deleted_at IS NULL", id, data)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetLabRevision возвращает ожидающую ревью правку лабы; nil, если её нет
+func (db *DB) GetLabRevision(id int) (*models.Lab, error) {
+	var data []byte
+	err := db.QueryRow("SELECT pending_revision FROM labs WHERE id = $1 AND deleted_at IS NULL", id).Scan(&data)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return unmarshalRevision(data)
+}
+
+// ApplyLabRevision переносит ожидающую правку в лабу и удаляет её одной
+// транзакцией. sql.ErrNoRows - правки нет; ошибки пререквизитов оставляют
+// правку на месте.
+func (db *DB) ApplyLabRevision(id int) (*models.Lab, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRow("SELECT pending_revision FROM labs WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, sql.ErrNoRows
+	}
+	rev, err := unmarshalRevision(data)
+	if err != nil {
+		return nil, err
+	}
+	rev.ID = id
+
+	if err := updateLab(tx, rev); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE labs SET pending_revision = NULL WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	return rev, commitLabRelations(tx, rev)
+}
+
+// unmarshalRevision разбирает сохранённую правку. Правка полная, поэтому пустые
+// списки, потерянные в JSON из-за omitempty, восстанавливаются как пустые, а не
+// как "не менять"
+func unmarshalRevision(data []byte) (*models.Lab, error) {
+	var rev models.Lab
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, err
+	}
+	if rev.Expected == nil {
+		rev.Expected = []models.StepExpectation{}
+	}
+	if rev.Variables == nil {
+		rev.Variables = []models.LabVariable{}
+	}
+	if rev.DestructiveSteps == nil {
+		rev.DestructiveSteps = []int{}
+	}
+	if rev.Tags == nil {
+		rev.Tags = []string{}
+	}
+	if rev.Requires == nil {
+		rev.Requires = []string{}
+	}
+	rev.Status = ""
+	rev.ClearSchedule = true
+	return &rev, nil
+}
+
+// AddLabReview добавляет запись в историю ревью лабы
+func (db *DB) AddLabReview(r *models.LabReview) error {
+	return db.QueryRow(`INSERT INTO lab_reviews (lab_id, user_id, action, comment)
+	                    VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		r.LabID, r.UserID, r.Action, r.Comment).Scan(&r.ID, &r.CreatedAt)
+}
+
+// GetLabReviews возвращает историю ревью лабы в хронологическом порядке
+func (db *DB) GetLabReviews(labID int) ([]models.LabReview, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.lab_id, COALESCE(r.user_id, 0), COALESCE(u.username, ''), r.action, r.comment, r.created_at
+		FROM lab_reviews r
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.lab_id = $1
+		ORDER BY r.created_at`, labID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.LabReview{}
+	for rows.Next() {
+		var r models.LabReview
+		if err := rows.Scan(&r.ID, &r.LabID, &r.UserID, &r.Username, &r.Action, &r.Comment, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
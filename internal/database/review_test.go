@@ -0,0 +1,32 @@
+package database
+
+import (
+	"devops-manual/internal/models"
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalRevision(t *testing.T) {
+	// Правка очищает списки: после JSON с omitempty они должны остаться пустыми, а не "не менять"
+	data, _ := json.Marshal(&models.Lab{
+		Title:            "Docker",
+		Commands:         []string{"docker ps"},
+		Status:           models.LabStatusPublished,
+		Expected:         []models.StepExpectation{},
+		DestructiveSteps: []int{},
+	})
+
+	rev, err := unmarshalRevision(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Expected == nil || rev.Variables == nil || rev.DestructiveSteps == nil || rev.Tags == nil || rev.Requires == nil {
+		t.Errorf("empty lists restored as nil: %+v", rev)
+	}
+	if rev.Status != "" || !rev.ClearSchedule {
+		t.Errorf("Status = %q, ClearSchedule = %v; revision must keep status and write schedule as is", rev.Status, rev.ClearSchedule)
+	}
+	if rev.Title != "Docker" || len(rev.Commands) != 1 {
+		t.Errorf("unexpected revision %+v", rev)
+	}
+}
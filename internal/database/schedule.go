@@ -0,0 +1,67 @@
+package database
+
+import (
+	"devops-manual/internal/models"
+)
+
+// schedulerLockKey - ключ advisory lock, чтобы при нескольких репликах
+// плановые переходы выполняла только одна из них
+const schedulerLockKey = 0x6c616273 // "labs"
+
+// RunScheduledTransitions публикует лабы, у которых наступил publish_at,
+// и архивирует опубликованные лабы с истёкшим unpublish_at.
+// Если блокировку держит другая реплика, ничего не делает.
+func (db *DB) RunScheduledTransitions() (published, archived []models.Lab, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", schedulerLockKey).Scan(&locked); err != nil {
+		return nil, nil, err
+	}
+	if !locked {
+		return nil, nil, nil
+	}
+
+	collect := func(query string) ([]models.Lab, error) {
+		rows, err := tx.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var labs []models.Lab
+		for rows.Next() {
+			var l models.Lab
+			if err := rows.Scan(&l.ID, &l.TopicID, &l.Title, &l.Slug, &l.Status); err != nil {
+				return nil, err
+			}
+			labs = append(labs, l)
+		}
+		return labs, rows.Err()
+	}
+
+	published, err = collect(`
+		UPDATE labs SET status = 'published', updated_at = NOW()
+		WHERE status = 'scheduled' AND deleted_at IS NULL
+		  AND (publish_at IS NULL OR publish_at <= NOW())
+		  AND (unpublish_at IS NULL OR unpublish_at > NOW())
+		RETURNING id, topic_id, title, slug, status`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	archived, err = collect(`
+		UPDATE labs SET status = 'archived', updated_at = NOW(), pending_revision = NULL
+		WHERE status IN ('published', 'scheduled') AND deleted_at IS NULL
+		  AND unpublish_at <= NOW()
+		RETURNING id, topic_id, title, slug, status`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return published, archived, tx.Commit()
+}
@@ -0,0 +1,22 @@
+package database
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Docker Compose":     "docker-compose",
+		" CI/CD ":            "cicd",
+		"k8s-1.29":           "k8s-129",
+		"Сети":               "seti",
+		"Мониторинг и логи":  "monitoring-i-logi",
+		"Щит Ёжика, объём":   "shchit-yozhika-obyom",
+		"Безопасность Linux": "bezopasnost-linux",
+		"!!! 🚀":              "",
+		"":                   "",
+	}
+	for name, want := range tests {
+		if got := slugify(name); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/models"
+	"errors"
+	"strings"
+)
+
+// ErrEmptySlug - из названия тега не получается slug: нет ни букв, ни цифр
+var ErrEmptySlug = errors.New("tag name must contain letters or digits")
+
+const tagSelect = `
+	SELECT t.id, t.name, t.slug, t.created_at,
+	       (SELECT COUNT(*) FROM lab_tags lt JOIN labs l ON l.id = lt.lab_id
+	        WHERE lt.tag_id = t.id AND l.deleted_at IS NULL)
+	FROM tags t`
+
+func scanTag(row rowScanner) (*models.Tag, error) {
+	var t models.Tag
+	if err := row.Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt, &t.LabCount); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (db *DB) GetTags() ([]models.Tag, error) {
+	rows, err := db.Query(tagSelect + " ORDER BY t.slug")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *t)
+	}
+	return tags, rows.Err()
+}
+
+func (db *DB) GetTagByID(id int) (*models.Tag, error) {
+	return scanTag(db.QueryRow(tagSelect+" WHERE t.id = $1", id))
+}
+
+func (db *DB) GetTagBySlug(slug string) (*models.Tag, error) {
+	return scanTag(db.QueryRow(tagSelect+" WHERE t.slug = $1", slug))
+}
+
+func (db *DB) CreateTag(tag *models.Tag) error {
+	tag.Slug = slugify(tag.Name)
+	if tag.Slug == "" {
+		return ErrEmptySlug
+	}
+	return db.QueryRow("INSERT INTO tags (name, slug) VALUES ($1, $2) RETURNING id, created_at",
+		tag.Name, tag.Slug).Scan(&tag.ID, &tag.CreatedAt)
+}
+
+func (db *DB) UpdateTag(tag *models.Tag) error {
+	tag.Slug = slugify(tag.Name)
+	if tag.Slug == "" {
+		return ErrEmptySlug
+	}
+	res, err := db.Exec("UPDATE tags SET name = $1, slug = $2 WHERE id = $3", tag.Name, tag.Slug, tag.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DB) DeleteTag(id int) error {
+	res, err := db.Exec("DELETE FROM tags WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// setLabTags заменяет теги лабы; отсутствующие теги создаются. Значения
+// нормализуются в slug; lab.Tags не меняется, итоговые slug'и возвращаются,
+// чтобы вызывающий записал их после коммита
+func setLabTags(tx *sql.Tx, lab *models.Lab) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM lab_tags WHERE lab_id = $1", lab.ID); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	slugs := []string{}
+	for _, name := range lab.Tags {
+		name = strings.TrimSpace(name)
+		slug := slugify(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		var tagID int
+		err := tx.QueryRow(`INSERT INTO tags (name, slug) VALUES ($1, $2)
+		                    ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+		                    RETURNING id`, name, slug).Scan(&tagID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("INSERT INTO lab_tags (lab_id, tag_id) VALUES ($1, $2)", lab.ID, tagID); err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+	return slugs, nil
+}
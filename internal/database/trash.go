@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"devops-manual/internal/models"
+	"log"
+	"strconv"
+	"time"
+)
+
+// GetDeletedLabs возвращает содержимое корзины, последние удалённые сверху
+func (db *DB) GetDeletedLabs() ([]models.Lab, error) {
+	rows, err := db.Query(labSelect + `
+		WHERE l.deleted_at IS NOT NULL
+		ORDER BY l.deleted_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labs := []models.Lab{}
+	for rows.Next() {
+		l, err := scanLab(rows)
+		if err != nil {
+			return nil, err
+		}
+		labs = append(labs, *l)
+	}
+	return labs, rows.Err()
+}
+
+// GetDeletedLabByID ищет лабу только среди удалённых
+func (db *DB) GetDeletedLabByID(id int) (*models.Lab, error) {
+	return scanLab(db.QueryRow(labSelect+`
+		WHERE l.id = $1 AND l.deleted_at IS NOT NULL`, id))
+}
+
+// RestoreLab возвращает лабу из корзины. Если slug уже занят новой лабой
+// той же темы, Postgres вернёт ошибку уникальности.
+func (db *DB) RestoreLab(id int) error {
+	res, err := db.Exec("UPDATE labs SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeLab окончательно удаляет лабу, находящуюся в корзине
+func (db *DB) PurgeLab(id int) error {
+	res, err := db.Exec("DELETE FROM labs WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeDeletedLabs окончательно удаляет лабы, пролежавшие в корзине дольше retention
+func (db *DB) PurgeDeletedLabs(retention time.Duration) ([]int, error) {
+	rows, err := db.Query("DELETE FROM labs WHERE deleted_at < $1 RETURNING id",
+		time.Now().Add(-retention))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// StartTrashPurge периодически чистит корзину от лаб старше retention
+func (db *DB) StartTrashPurge(retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for ; true; <-ticker.C {
+			ids, err := db.PurgeDeletedLabs(retention)
+			if err != nil {
+				log.Println("ERROR trash purge:", err)
+				continue
+			}
+			for _, id := range ids {
+				db.AddAuditEvent(&models.AuditEvent{
+					ActorName:  "system",
+					Action:     "lab.purge",
+					TargetType: "lab",
+					TargetID:   strconv.Itoa(id),
+				})
+			}
+			if len(ids) > 0 {
+				log.Printf("Trash purge: removed %d labs", len(ids))
+			}
+		}
+	}()
+}
@@ -0,0 +1,89 @@
+// Package document собирает тему со всеми лабами в один документ для печати
+// и чтения офлайн: HTML, EPUB или PDF. Только чистый Go, без браузера, поэтому
+// работает и в минимальном контейнере.
+package document
+
+import (
+	"devops-manual/internal/labvars"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Форматы документа
+const (
+	FormatHTML = "html"
+	FormatEPUB = "epub"
+	FormatPDF  = "pdf"
+)
+
+// Formats - поддерживаемые форматы
+var Formats = []string{FormatPDF, FormatEPUB, FormatHTML}
+
+// ErrUnknownFormat - запрошен неподдерживаемый формат
+var ErrUnknownFormat = errors.New("unknown document format")
+
+// Document - тема с лабами-главами
+type Document struct {
+	ID          string // постоянный идентификатор, нужен EPUB
+	Title       string
+	Description string
+	Chapters    []Chapter
+}
+
+// Chapter - лаба с подставленными значениями переменных по умолчанию
+type Chapter struct {
+	Title      string
+	Difficulty string
+	Content    string
+	Commands   []string
+}
+
+// FromTopic собирает документ из темы и её лаб в переданном порядке
+func FromTopic(topic *models.Topic, labs []models.Lab) *Document {
+	doc := &Document{
+		ID:          "urn:devops-manual:topic:" + topic.Slug,
+		Title:       topic.Title,
+		Description: topic.Description,
+	}
+	for i := range labs {
+		lab := labvars.Defaults(&labs[i])
+		doc.Chapters = append(doc.Chapters, Chapter{
+			Title:      lab.Title,
+			Difficulty: lab.Difficulty,
+			Content:    lab.Content,
+			Commands:   lab.Commands,
+		})
+	}
+	return doc
+}
+
+// Write пишет документ в формате format
+func (d *Document) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatHTML:
+		return d.HTML(w)
+	case FormatEPUB:
+		return d.EPUB(w)
+	case FormatPDF:
+		return d.PDF(w)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// ContentType - MIME тип формата
+func ContentType(format string) string {
+	switch format {
+	case FormatEPUB:
+		return "application/epub+zip"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return "text/html; charset=utf-8"
+}
+
+// chapterID - якорь главы в HTML и имя файла в EPUB
+func chapterID(i int) string {
+	return fmt.Sprintf("lab-%d", i+1)
+}
@@ -0,0 +1,142 @@
+package document
+
+import (
+	"archive/zip"
+	"devops-manual/internal/markdown"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EPUB пишет документ в формате EPUB 3: оглавление nav.xhtml и по файлу на лабу
+func (d *Document) EPUB(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	// mimetype - первым и без сжатия, этого требует спецификация
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mt, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		"META-INF/container.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>
+`,
+		"OEBPS/style.css":   style,
+		"OEBPS/content.opf": d.epubPackage(),
+		"OEBPS/nav.xhtml":   d.epubNav(),
+	}
+	for i, ch := range d.Chapters {
+		files["OEBPS/"+chapterID(i)+".xhtml"] = epubChapter(i, ch)
+	}
+
+	// Порядок файлов в архиве не важен, кроме mimetype; пишем детерминированно
+	names := []string{"META-INF/container.xml", "OEBPS/style.css", "OEBPS/content.opf", "OEBPS/nav.xhtml"}
+	for i := range d.Chapters {
+		names = append(names, "OEBPS/"+chapterID(i)+".xhtml")
+	}
+	for _, name := range names {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (d *Document) epubPackage() string {
+	var manifest, spine strings.Builder
+	for i := range d.Chapters {
+		id := chapterID(i)
+		fmt.Fprintf(&manifest, `<item id="%s" href="%s.xhtml" media-type="application/xhtml+xml"/>`+"\n", id, id)
+		fmt.Fprintf(&spine, `<itemref idref="%s"/>`+"\n", id)
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="ru">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>ru</dc:language>
+<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="style" href="style.css" media-type="text/css"/>
+%s</manifest>
+<spine>
+<itemref idref="nav"/>
+%s</spine>
+</package>
+`, html.EscapeString(d.ID), html.EscapeString(d.Title), time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		manifest.String(), spine.String())
+}
+
+func (d *Document) epubNav() string {
+	var items strings.Builder
+	for i, ch := range d.Chapters {
+		fmt.Fprintf(&items, `<li><a href="%s.xhtml">%s</a></li>`+"\n", chapterID(i), html.EscapeString(ch.Title))
+	}
+
+	description := ""
+	if d.Description != "" {
+		description = "<p>" + html.EscapeString(d.Description) + "</p>\n"
+	}
+	return xhtmlPage(d.Title, fmt.Sprintf(`<h1>%s</h1>
+%s<nav epub:type="toc" id="toc">
+<h3>Содержание</h3>
+<ol>
+%s</ol>
+</nav>
+`, html.EscapeString(d.Title), description, items.String()))
+}
+
+func epubChapter(i int, ch Chapter) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h2>%d. %s</h2>\n", i+1, html.EscapeString(ch.Title))
+	fmt.Fprintf(&b, "<p class=\"meta\">Сложность: %s</p>\n", html.EscapeString(ch.Difficulty))
+	b.WriteString(epubContent(ch.Content) + "\n")
+	if len(ch.Commands) > 0 {
+		b.WriteString("<h3>Команды</h3>\n<ol class=\"commands\">\n")
+		for _, cmd := range ch.Commands {
+			fmt.Fprintf(&b, "<li><code>%s</code></li>\n", html.EscapeString(cmd))
+		}
+		b.WriteString("</ol>\n")
+	}
+	return xhtmlPage(ch.Title, b.String())
+}
+
+var imgRe = regexp.MustCompile(`<img src="[^"]*" alt="([^"]*)"[^>]*>`)
+
+// epubContent рендерит Markdown в XHTML. Вложения лежат на сервере и в книгу
+// не попадают, поэтому картинки заменяются подписью.
+func epubContent(content string) string {
+	s := imgRe.ReplaceAllString(markdown.Render(content), "[$1]")
+	return strings.ReplaceAll(s, "<br>", "<br/>")
+}
+
+func xhtmlPage(title, body string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="ru" lang="ru">
+<head>
+<meta charset="UTF-8"/>
+<title>%s</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+%s</body>
+</html>
+`, html.EscapeString(title), body)
+}
@@ -0,0 +1,58 @@
+package document
+
+import (
+	"devops-manual/internal/markdown"
+	"html/template"
+	"io"
+)
+
+// style - оформление для печати, общее для HTML и EPUB
+const style = `
+body { font-family: sans-serif; line-height: 1.5; max-width: 50em; margin: 0 auto; padding: 1em; color: #111; }
+h1 { font-size: 2em; }
+h2 { page-break-before: always; border-bottom: 1px solid #999; }
+.meta { color: #666; }
+ol.commands li { margin-bottom: 0.5em; }
+ol.commands code, pre { background: #f2f2f2; padding: 0.2em 0.4em; white-space: pre-wrap; word-break: break-all; }
+nav ol { padding-left: 1.5em; }
+`
+
+var htmlTmpl = template.Must(template.New("document").Funcs(template.FuncMap{
+	"add":      func(a, b int) int { return a + b },
+	"id":       chapterID,
+	"markdown": func(s string) template.HTML { return template.HTML(markdown.Render(s)) },
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}}</title>
+<style>` + style + `</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<nav>
+<h3>Содержание</h3>
+<ol>
+{{range $i, $ch := .Chapters}}<li><a href="#{{id $i}}">{{$ch.Title}}</a></li>
+{{end}}</ol>
+</nav>
+{{range $i, $ch := .Chapters}}
+<section id="{{id $i}}">
+<h2>{{add $i 1}}. {{$ch.Title}}</h2>
+<p class="meta">Сложность: {{$ch.Difficulty}}</p>
+{{markdown $ch.Content}}
+{{if $ch.Commands}}<h3>Команды</h3>
+<ol class="commands">
+{{range $ch.Commands}}<li><code>{{.}}</code></li>
+{{end}}</ol>{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+// HTML пишет документ одной страницей с оглавлением по якорям
+func (d *Document) HTML(w io.Writer) error {
+	return htmlTmpl.Execute(w, d)
+}
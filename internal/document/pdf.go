@@ -0,0 +1,156 @@
+package document
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Шрифт DejaVu (свободная лицензия) встраивается в бинарник: стандартные
+// шрифты PDF не содержат кириллицы
+//
+//go:embed fonts/DejaVuSansCondensed.ttf
+var dejaVu []byte
+
+const (
+	pdfFont   = "dejavu"
+	pdfMargin = 20.0
+	pdfLine   = 5.5
+)
+
+// PDF пишет документ в A4 с оглавлением, номерами страниц и закладками.
+// Номера страниц глав заранее неизвестны, поэтому документ собирается дважды:
+// первый проход только считает, с какой страницы начинается каждая глава.
+func (d *Document) PDF(w io.Writer) error {
+	first, starts := d.pdf(nil)
+	if err := first.Error(); err != nil {
+		return err
+	}
+	pdf, _ := d.pdf(starts)
+	return pdf.Output(w)
+}
+
+// pdf рендерит документ; starts - страницы глав для оглавления (nil - первый проход)
+func (d *Document) pdf(starts []int) (*gofpdf.Fpdf, []int) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.AddUTF8FontFromBytes(pdfFont, "", dejaVu)
+	pdf.SetTitle(d.Title, true)
+	pdf.SetCreator("DevOps Manual", true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin + 5)
+		pdf.SetFont(pdfFont, "", 9)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 5, fmt.Sprint(pdf.PageNo()), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	width, _ := pdf.GetPageSize()
+	textWidth := width - 2*pdfMargin
+
+	links := make([]int, len(d.Chapters))
+	for i := range links {
+		links[i] = pdf.AddLink()
+	}
+
+	// Титул и оглавление
+	pdf.AddPage()
+	pdf.SetFont(pdfFont, "", 22)
+	pdf.MultiCell(0, 10, d.Title, "", "L", false)
+	if d.Description != "" {
+		pdf.Ln(2)
+		pdf.SetFont(pdfFont, "", 11)
+		pdf.MultiCell(0, pdfLine, d.Description, "", "L", false)
+	}
+	pdf.Ln(6)
+	pdf.SetFont(pdfFont, "", 14)
+	pdf.CellFormat(0, 8, "Содержание", "", 1, "L", false, 0, "")
+	pdf.SetFont(pdfFont, "", 11)
+	for i, ch := range d.Chapters {
+		page := ""
+		if starts != nil {
+			page = fmt.Sprint(starts[i])
+		}
+		// Одна строка на главу, чтобы оглавление занимало одинаково места в обоих проходах
+		pageWidth := 15.0
+		title := fitText(pdf, fmt.Sprintf("%d. %s", i+1, ch.Title), textWidth-pageWidth)
+		pdf.CellFormat(textWidth-pageWidth, 7, title, "", 0, "L", false, links[i], "")
+		pdf.CellFormat(pageWidth, 7, page, "", 1, "R", false, links[i], "")
+	}
+
+	found := make([]int, len(d.Chapters))
+	for i, ch := range d.Chapters {
+		pdf.AddPage()
+		found[i] = pdf.PageNo()
+		pdf.SetLink(links[i], -1, -1)
+		pdf.SetFont(pdfFont, "", 16)
+		pdf.Bookmark(ch.Title, 0, -1)
+		pdf.MultiCell(0, 8, fmt.Sprintf("%d. %s", i+1, ch.Title), "", "L", false)
+
+		pdf.SetFont(pdfFont, "", 10)
+		pdf.SetTextColor(100, 100, 100)
+		pdf.MultiCell(0, pdfLine, "Сложность: "+ch.Difficulty, "", "L", false)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(3)
+
+		pdfContent(pdf, ch.Content)
+
+		if len(ch.Commands) > 0 {
+			pdf.Ln(3)
+			pdf.SetFont(pdfFont, "", 13)
+			pdf.CellFormat(0, 8, "Команды", "", 1, "L", false, 0, "")
+			pdf.SetFillColor(242, 242, 242)
+			for j, cmd := range ch.Commands {
+				pdf.SetFont(pdfFont, "", 10)
+				pdf.CellFormat(8, pdfLine, fmt.Sprintf("%d.", j+1), "", 0, "R", false, 0, "")
+				pdf.SetX(pdfMargin + 10)
+				pdf.MultiCell(textWidth-10, pdfLine, cmd, "", "L", true)
+				pdf.Ln(1.5)
+			}
+		}
+	}
+	return pdf, found
+}
+
+// pdfContent выводит markdown как текст: заголовки крупнее, блоки кода на сером
+// фоне, остальная разметка остаётся как есть - она читаема и без рендеринга
+func pdfContent(pdf *gofpdf.Fpdf, content string) {
+	pdf.SetFillColor(242, 242, 242)
+	inCode := false
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			inCode = !inCode
+		case inCode:
+			pdf.SetFont(pdfFont, "", 9.5)
+			pdf.MultiCell(0, pdfLine, line, "", "L", true)
+		case strings.HasPrefix(trimmed, "#"):
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			pdf.Ln(2)
+			pdf.SetFont(pdfFont, "", 15-float64(min(level, 4)))
+			pdf.MultiCell(0, 7, strings.TrimSpace(trimmed[level:]), "", "L", false)
+		case trimmed == "":
+			pdf.Ln(pdfLine / 2)
+		default:
+			pdf.SetFont(pdfFont, "", 11)
+			pdf.MultiCell(0, pdfLine, line, "", "L", false)
+		}
+	}
+}
+
+// fitText обрезает строку до ширины width с многоточием
+func fitText(pdf *gofpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"devops-manual/internal/blobstore"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Типы файлов, которые можно прикрепить к лабе, по результату http.DetectContentType
+var attachmentTypes = map[string]bool{
+	"image/png":          true,
+	"image/jpeg":         true,
+	"image/gif":          true,
+	"image/webp":         true,
+	"application/pdf":    true,
+	"application/zip":    true,
+	"application/x-gzip": true,
+}
+
+// attachmentType определяет MIME тип по содержимому. Текст (конфиги, YAML,
+// скрипты) отдаётся как text/plain, SVG распознаётся по расширению и разметке.
+// Второй результат false - тип не разрешён.
+func attachmentType(filename string, data []byte) (string, bool) {
+	sniffed := http.DetectContentType(data)
+	base := strings.TrimSpace(strings.SplitN(sniffed, ";", 2)[0])
+	if attachmentTypes[base] {
+		return base, true
+	}
+	switch base {
+	case "text/plain", "text/xml":
+		head := data
+		if len(head) > 1024 {
+			head = head[:1024]
+		}
+		if strings.EqualFold(filepath.Ext(filename), ".svg") && bytes.Contains(head, []byte("<svg")) {
+			return "image/svg+xml", true
+		}
+		return "text/plain; charset=utf-8", true
+	}
+	return sniffed, false
+}
+
+// cleanFilename оставляет от имени файла только безопасную для заголовков и URL часть
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[len(runes)-100:])
+	}
+	return name
+}
+
+// attachmentMarkdown - ссылка на вложение для вставки в текст лабы
+func attachmentMarkdown(a *models.Attachment) string {
+	if strings.HasPrefix(a.ContentType, "image/") {
+		return fmt.Sprintf("![%s](%s)", a.Filename, a.URL)
+	}
+	return fmt.Sprintf("[%s](%s)", a.Filename, a.URL)
+}
+
+// UploadAttachment - POST /api/labs/:id/attachments (multipart, поле file)
+func (h *Handler) UploadAttachment(c *gin.Context) {
+	if h.Blobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Attachment storage is not configured"})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	lab, err := h.DB.GetLabByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
+		return
+	}
+
+	// Запас на заголовки multipart сверх самого файла
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.AttachmentMaxBytes+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d MB", h.AttachmentMaxBytes>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, h.AttachmentMaxBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if int64(len(data)) > h.AttachmentMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d MB", h.AttachmentMaxBytes>>20)})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is empty"})
+		return
+	}
+
+	filename := cleanFilename(header.Filename)
+	contentType, ok := attachmentType(filename, data)
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type " + contentType + " is not allowed"})
+		return
+	}
+
+	key := blobstore.Key(data)
+	if err := h.Blobs.Put(c.Request.Context(), key, bytes.NewReader(data), int64(len(data))); err != nil {
+		log.Println("ERROR UploadAttachment:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	a := &models.Attachment{
+		LabID:       lab.ID,
+		BlobKey:     key,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		UserID:      c.GetInt("user_id"),
+	}
+	if err := h.DB.CreateAttachment(a); err != nil {
+		log.Println("ERROR UploadAttachment:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "attachment.create", "lab", lab.ID, nil, a)
+	c.JSON(http.StatusCreated, gin.H{"attachment": a, "markdown": attachmentMarkdown(a)})
+}
+
+// GetAttachments - GET /api/attachments?lab_id=: вложения лабы.
+// GET /api/labs/:id/... занят маршрутом /api/labs/:topic/:lab.
+func (h *Handler) GetAttachments(c *gin.Context) {
+	labID, err := strconv.Atoi(c.Query("lab_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lab_id is required"})
+		return
+	}
+	if _, ok := h.visibleLab(c, labID, h.viewer(c)); !ok {
+		return
+	}
+
+	attachments, err := h.DB.GetAttachments(labID)
+	if err != nil {
+		log.Println("ERROR GetAttachments:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DeleteAttachment - DELETE /api/labs/:id/attachments/:attachment_id
+func (h *Handler) DeleteAttachment(c *gin.Context) {
+	labID, _ := strconv.Atoi(c.Param("id"))
+	id, _ := strconv.Atoi(c.Param("attachment_id"))
+	a, err := h.DB.GetAttachment(id)
+	if err != nil || a.LabID != labID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	if err := h.DB.DeleteAttachment(id); err != nil {
+		log.Println("ERROR DeleteAttachment:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "attachment.delete", "lab", labID, a, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+// ServeAttachment - GET /attachments/:id/:filename. Имя в URL только для
+// читаемости ссылок, файл ищется по id. Вложения черновиков видят только редакторы.
+func (h *Handler) ServeAttachment(c *gin.Context) {
+	if h.Blobs == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	a, err := h.DB.GetAttachment(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	lab, err := h.DB.GetLabByID(a.LabID)
+	if err != nil || (lab.Status != models.LabStatusPublished && !canEdit(h.viewer(c))) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	blob, err := h.Blobs.Get(c.Request.Context(), a.BlobKey)
+	if err != nil {
+		log.Println("ERROR ServeAttachment:", err)
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer blob.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(a.ContentType, "image/") || strings.HasPrefix(a.ContentType, "text/plain") || a.ContentType == "application/pdf" {
+		disposition = "inline"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`%s; filename="%s"`, disposition, a.Filename))
+	// SVG может содержать скрипты: запрещаем всё, кроме стилей
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	c.Header("X-Content-Type-Options", "nosniff")
+	// Содержимое по id не меняется
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.DataFromReader(http.StatusOK, a.Size, a.ContentType, blob, nil)
+}
+
+// StartBlobCleanup раз в interval удаляет блобы, на которые не ссылается ни
+// одно вложение (удалённые вложения, окончательно удалённые лабы). Блобы моложе
+// grace не трогаются: их могли только что записать, а строку ещё не вставить.
+func (h *Handler) StartBlobCleanup(interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for ; true; <-ticker.C {
+			n, err := h.cleanupBlobs(context.Background(), grace)
+			if err != nil {
+				log.Println("ERROR cleanupBlobs:", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("🧹 Removed %d unused attachment blobs", n)
+			}
+		}
+	}()
+}
+
+func (h *Handler) cleanupBlobs(ctx context.Context, grace time.Duration) (int, error) {
+	// Используемые ключи читаем до листинга: загрузка сначала пишет или
+	// обновляет блоб (время изменения свежее) и только потом вставляет строку
+	used, err := h.DB.GetUsedBlobKeys()
+	if err != nil {
+		return 0, err
+	}
+	blobs, err := h.Blobs.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-grace)
+	for _, b := range blobs {
+		if used[b.Key] || b.ModTime.After(cutoff) {
+			continue
+		}
+		// Строка могла появиться после листинга: перепроверяем перед удалением
+		inUse, err := h.DB.IsBlobKeyUsed(b.Key)
+		if err != nil {
+			return removed, err
+		}
+		if inUse {
+			continue
+		}
+		if err := h.Blobs.Delete(ctx, b.Key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// audit пишет событие от имени пользователя из контекста (AuthMiddleware)
+func (h *Handler) audit(c *gin.Context, action, targetType string, targetID interface{}, before, after interface{}) {
+	h.auditAs(c, currentUser(c), action, targetType, targetID, before, after)
+}
+
+// auditAs пишет событие от имени явно переданного пользователя (nil - аноним).
+// Ошибка записи не прерывает запрос, только логируется.
+func (h *Handler) auditAs(c *gin.Context, actor *models.User, action, targetType string, targetID interface{}, before, after interface{}) {
+	e := &models.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if targetID != nil {
+		e.TargetID = fmt.Sprint(targetID)
+	}
+	if actor != nil {
+		id := actor.ID
+		e.ActorID = &id
+		e.ActorName = actor.Username
+	}
+	if before != nil {
+		e.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		e.After, _ = json.Marshal(after)
+	}
+
+	if err := h.DB.AddAuditEvent(e); err != nil {
+		log.Println("ERROR audit:", action, err)
+	}
+}
+
+// GetAudit - GET /api/audit?actor_id=&action=&target_type=&target_id=&since=&until=&limit=&offset=
+func (h *Handler) GetAudit(c *gin.Context) {
+	f := models.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+	f.ActorID, _ = strconv.Atoi(c.Query("actor_id"))
+	f.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	f.Limit = database.PageLimit(f.Limit)
+	f.Offset, _ = strconv.Atoi(c.Query("offset"))
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	var err error
+	if f.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since: " + err.Error()})
+		return
+	}
+	if f.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until: " + err.Error()})
+		return
+	}
+
+	events, total, err := h.DB.GetAuditEvents(f)
+	if err != nil {
+		log.Println("ERROR GetAudit:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"limit":  f.Limit,
+		"offset": f.Offset,
+	})
+}
+
+// parseTimeParam принимает RFC3339 или дату YYYY-MM-DD; пустая строка - нулевое время
+func parseTimeParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBookmarks - GET /api/me/bookmarks
+func (h *Handler) GetBookmarks(c *gin.Context) {
+	bookmarks, err := h.DB.GetBookmarks(c.GetInt("user_id"), !canEdit(currentUser(c)))
+	if err != nil {
+		log.Println("ERROR GetBookmarks:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, bookmarks)
+}
+
+// AddBookmark - POST /api/me/bookmarks {"lab_id": 1}
+func (h *Handler) AddBookmark(c *gin.Context) {
+	var req struct {
+		LabID int `json:"lab_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.LabID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lab_id is required"})
+		return
+	}
+
+	lab, ok := h.visibleLab(c, req.LabID, currentUser(c))
+	if !ok {
+		return
+	}
+
+	if err := h.DB.AddBookmark(c.GetInt("user_id"), lab.ID); err != nil {
+		log.Println("ERROR AddBookmark:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bookmarked": true})
+}
+
+// RemoveBookmark - DELETE /api/me/bookmarks/:lab_id
+func (h *Handler) RemoveBookmark(c *gin.Context) {
+	labID, _ := strconv.Atoi(c.Param("lab_id"))
+	if err := h.DB.RemoveBookmark(c.GetInt("user_id"), labID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+			return
+		}
+		log.Println("ERROR RemoveBookmark:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bookmarked": false})
+}
+
+// GetCollections - GET /api/me/collections
+func (h *Handler) GetCollections(c *gin.Context) {
+	collections, err := h.DB.GetCollections(c.GetInt("user_id"), !canEdit(currentUser(c)))
+	if err != nil {
+		log.Println("ERROR GetCollections:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, collections)
+}
+
+// CreateCollection - POST /api/me/collections {"name": "...", "lab_ids": [1, 2]}
+func (h *Handler) CreateCollection(c *gin.Context) {
+	col, ok := bindCollection(c)
+	if !ok {
+		return
+	}
+	col.UserID = c.GetInt("user_id")
+
+	if err := h.DB.CreateCollection(col); err != nil {
+		log.Println("ERROR CreateCollection:", err)
+		collectionError(c, err)
+		return
+	}
+
+	created, _ := h.DB.GetCollection(col.ID, col.UserID, !canEdit(currentUser(c)))
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateCollection - PUT /api/me/collections/:id, список лаб заменяется целиком
+func (h *Handler) UpdateCollection(c *gin.Context) {
+	col, ok := bindCollection(c)
+	if !ok {
+		return
+	}
+	col.ID, _ = strconv.Atoi(c.Param("id"))
+	col.UserID = c.GetInt("user_id")
+
+	if err := h.DB.UpdateCollection(col); err != nil {
+		log.Println("ERROR UpdateCollection:", err)
+		collectionError(c, err)
+		return
+	}
+
+	updated, _ := h.DB.GetCollection(col.ID, col.UserID, !canEdit(currentUser(c)))
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteCollection - DELETE /api/me/collections/:id
+func (h *Handler) DeleteCollection(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.DB.DeleteCollection(id, c.GetInt("user_id")); err != nil {
+		collectionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+// ShareCollection - POST /api/me/collections/:id/share: новая ссылка только для чтения.
+// Повторный вызов выдаёт новую ссылку, старая перестаёт работать.
+func (h *Handler) ShareCollection(c *gin.Context) {
+	h.setCollectionShared(c, true)
+}
+
+// UnshareCollection - DELETE /api/me/collections/:id/share: отзывает ссылку
+func (h *Handler) UnshareCollection(c *gin.Context) {
+	h.setCollectionShared(c, false)
+}
+
+func (h *Handler) setCollectionShared(c *gin.Context, shared bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	token, err := h.DB.SetCollectionShared(id, c.GetInt("user_id"), shared)
+	if err != nil {
+		log.Println("ERROR setCollectionShared:", err)
+		collectionError(c, err)
+		return
+	}
+
+	resp := gin.H{"shared": shared}
+	if shared {
+		resp["share_token"] = token
+		resp["url"] = "/c/" + token
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetSharedCollection - GET /api/collections/:token, публичная подборка
+func (h *Handler) GetSharedCollection(c *gin.Context) {
+	col, err := h.DB.GetSharedCollection(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	col.ShareToken = ""
+	c.JSON(http.StatusOK, col)
+}
+
+// CollectionPage - /c/:token, страница подборки по ссылке
+func (h *Handler) CollectionPage(c *gin.Context) {
+	col, err := h.DB.GetSharedCollection(c.Param("token"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "404.html", nil)
+		return
+	}
+
+	c.HTML(http.StatusOK, "collection/collection.html", gin.H{
+		"title":      col.Name,
+		"collection": col,
+		"csrf":       csrfToken(c),
+	})
+}
+
+func bindCollection(c *gin.Context) (*models.Collection, bool) {
+	var req struct {
+		Name   string `json:"name"`
+		LabIDs []int  `json:"lab_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return nil, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required (up to 255 characters)"})
+		return nil, false
+	}
+	return &models.Collection{Name: req.Name, LabIDs: req.LabIDs}, true
+}
+
+func collectionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrUnknownLab):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
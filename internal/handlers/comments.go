@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxCommentLength = 10000
+
+// GetComments - GET /api/comments?lab_id= (GET /api/labs/:id/... занят /api/labs/:topic/:lab).
+// Админы видят и скрытые комментарии.
+func (h *Handler) GetComments(c *gin.Context) {
+	labID, err := strconv.Atoi(c.Query("lab_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lab_id is required"})
+		return
+	}
+
+	user := h.viewer(c)
+	if _, ok := h.visibleLab(c, labID, user); !ok {
+		return
+	}
+
+	isAdmin := user != nil && user.Role == models.RoleAdmin
+	comments, err := h.DB.GetLabComments(labID, isAdmin)
+	if err != nil {
+		log.Println("ERROR GetComments:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comments)
+}
+
+// CreateComment - POST /api/labs/:id/comments {"body": "...", "parent_id": 1, "step": 0}
+func (h *Handler) CreateComment(c *gin.Context) {
+	var req struct {
+		Body     string `json:"body"`
+		ParentID *int   `json:"parent_id"`
+		Step     *int   `json:"step"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if msg := validateCommentBody(req.Body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	user := currentUser(c)
+	labID, _ := strconv.Atoi(c.Param("id"))
+	lab, ok := h.visibleLab(c, labID, user)
+	if !ok {
+		return
+	}
+	if req.Step != nil && (*req.Step < 0 || *req.Step >= len(lab.Commands)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "step is out of range"})
+		return
+	}
+
+	comment := &models.Comment{
+		LabID:    lab.ID,
+		ParentID: req.ParentID,
+		UserID:   user.ID,
+		Step:     req.Step,
+		Body:     strings.TrimSpace(req.Body),
+	}
+	if err := h.DB.CreateComment(comment); err != nil {
+		if errors.Is(err, database.ErrInvalidParent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Println("ERROR CreateComment:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "comment.create", "comment", comment.ID, nil, comment)
+
+	// Автору лабы - уведомление о новом комментарии (кроме своих)
+	if lab.AuthorID != 0 && lab.AuthorID != user.ID {
+		msg := fmt.Sprintf("💬 %s прокомментировал лабу %s (автор: %s)", user.Username, lab.Title, lab.Author)
+		if comment.Step != nil {
+			msg += fmt.Sprintf(", команда #%d", *comment.Step+1)
+		}
+		go h.Monitor.SendAlert(msg)
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateComment - PUT /api/comments/:id, только автор
+func (h *Handler) UpdateComment(c *gin.Context) {
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if msg := validateCommentBody(req.Body); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	before, ok := h.loadComment(c)
+	if !ok {
+		return
+	}
+	if before.UserID != c.GetInt("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit a comment"})
+		return
+	}
+
+	if err := h.DB.UpdateComment(before.ID, strings.TrimSpace(req.Body)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return
+		}
+		log.Println("ERROR UpdateComment:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	after, _ := h.DB.GetCommentByID(before.ID)
+	h.audit(c, "comment.update", "comment", before.ID, before, after)
+	c.JSON(http.StatusOK, after)
+}
+
+// DeleteComment - DELETE /api/comments/:id. Автор удаляет свой комментарий
+// (ответы остаются), админ удаляет комментарий вместе с веткой.
+func (h *Handler) DeleteComment(c *gin.Context) {
+	before, ok := h.loadComment(c)
+	if !ok {
+		return
+	}
+
+	user := currentUser(c)
+	var err error
+	action := "comment.delete"
+	switch {
+	case before.UserID == user.ID && !before.Deleted:
+		err = h.DB.DeleteComment(before.ID)
+	case user.Role == models.RoleAdmin:
+		action = "comment.purge"
+		err = h.DB.PurgeComment(before.ID)
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author or an admin can delete a comment"})
+		return
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return
+		}
+		log.Println("ERROR DeleteComment:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, action, "comment", before.ID, before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+// HideComment - POST /api/comments/:id/hide (админ)
+func (h *Handler) HideComment(c *gin.Context) {
+	h.setCommentHidden(c, true)
+}
+
+// UnhideComment - POST /api/comments/:id/unhide (админ)
+func (h *Handler) UnhideComment(c *gin.Context) {
+	h.setCommentHidden(c, false)
+}
+
+func (h *Handler) setCommentHidden(c *gin.Context, hidden bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.DB.SetCommentHidden(id, hidden); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return
+		}
+		log.Println("ERROR setCommentHidden:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	action := "comment.hide"
+	if !hidden {
+		action = "comment.unhide"
+	}
+	h.audit(c, action, "comment", id, nil, nil)
+	c.JSON(http.StatusOK, gin.H{"hidden": hidden})
+}
+
+// visibleLab возвращает лабу, если пользователь может её видеть; иначе отвечает 404
+func (h *Handler) visibleLab(c *gin.Context, labID int, user *models.User) (*models.Lab, bool) {
+	lab, err := h.DB.GetLabByID(labID)
+	if err == nil && lab.Status != models.LabStatusPublished && !canEdit(user) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
+		return nil, false
+	}
+	return lab, true
+}
+
+// loadComment загружает комментарий из :id; при ошибке ответ уже отправлен
+func (h *Handler) loadComment(c *gin.Context) (*models.Comment, bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	comment, err := h.DB.GetCommentByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	return comment, true
+}
+
+func validateCommentBody(body string) string {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "Comment body is required"
+	}
+	if len(body) > maxCommentLength {
+		return fmt.Sprintf("Comment must be at most %d bytes", maxCommentLength)
+	}
+	return ""
+}
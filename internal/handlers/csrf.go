@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// CSRFMiddleware - double-submit защита: токен лежит в cookie и должен
+// быть продублирован в заголовке X-CSRF-Token на всех изменяющих запросах.
+// Запросы с Authorization: Bearer не используют cookie и от проверки освобождены.
+func (h *Handler) CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := c.Cookie(csrfCookie)
+		if err != nil || len(token) != 64 {
+			token = newCSRFToken()
+			h.setCookie(c, csrfCookie, token, 0, "/")
+		}
+		c.Set("csrf_token", token)
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if bearerToken(c) != "" {
+			c.Next()
+			return
+		}
+
+		sent := c.GetHeader(csrfHeader)
+		if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			log.Println("ERROR CSRFMiddleware: token mismatch for", c.Request.Method, c.Request.URL.Path)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// csrfToken возвращает токен текущего запроса для передачи в шаблон
+func csrfToken(c *gin.Context) string {
+	return c.GetString("csrf_token")
+}
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer
+func bearerToken(c *gin.Context) string {
+	auth := c.GetHeader("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
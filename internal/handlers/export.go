@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bytes"
+	"devops-manual/internal/document"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportTopic - GET /api/topics/:slug/export?format=pdf|epub|html
+// Тема со всеми лабами одним документом: оглавление, текст и пронумерованные команды
+func (h *Handler) ExportTopic(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", document.FormatPDF))
+	if !oneOf(format, document.Formats) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of: " + strings.Join(document.Formats, ", ")})
+		return
+	}
+
+	slug := c.Param("slug")
+	topic, err := h.DB.GetTopicBySlug(slug)
+	if err != nil {
+		log.Println("ERROR ExportTopic:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		return
+	}
+	labs, err := h.DB.GetLabsByTopicSlug(slug, !canEdit(h.viewer(c)))
+	if err != nil {
+		log.Println("ERROR ExportTopic:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// В документе лабы идут в порядке добавления, как главы учебника
+	sort.SliceStable(labs, func(i, j int) bool { return labs[i].CreatedAt.Before(labs[j].CreatedAt) })
+
+	// Рендерим в буфер, чтобы ошибка не оборвала уже начатый ответ
+	var buf bytes.Buffer
+	if err := document.FromTopic(topic, labs).Write(&buf, format); err != nil {
+		log.Println("ERROR ExportTopic:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, topic.Slug, format))
+	c.Data(http.StatusOK, document.ContentType(format), buf.Bytes())
+}
@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"devops-manual/internal/blobstore"
+	"devops-manual/internal/config"
 	"devops-manual/internal/database"
+	"devops-manual/internal/labcheck"
+	"devops-manual/internal/labvars"
 	"devops-manual/internal/models"
 	"devops-manual/internal/monitoring"
-	"fmt"
+	"devops-manual/internal/oidc"
+	"devops-manual/internal/sandbox"
+	"errors"
+	"html/template"
 	"log"
 	"net/http"
 	"strconv"
@@ -17,16 +24,46 @@ import (
 type Handler struct {
 	DB       *database.DB
 	Monitor  *monitoring.Monitor
+	OIDC     *oidc.Provider   // nil, если SSO не настроен
+	Sandbox  *sandbox.Manager // nil, если песочница выключена
+
+	// Registration - режим саморегистрации: closed, open или invite
+	Registration string
+
+	// Blobs - хранилище вложений лаб; nil, если не настроено
+	Blobs blobstore.BlobStore
+	// AttachmentMaxBytes - предельный размер одного вложения
+	AttachmentMaxBytes int64
+
+	// SecureCookies - ставить cookie с флагом Secure (только по HTTPS)
+	SecureCookies bool
 }
 
-func New(db *database.DB) *Handler {
+func New(db *database.DB, cfg *config.Config) *Handler {
+	blobs, err := blobstore.NewFromConfig(cfg.Attachments)
+	if err != nil {
+		log.Println("Attachments disabled:", err)
+	}
+
 	return &Handler{
 		DB:      db,
-		Monitor: monitoring.NewMonitor(db.DB),
+		Monitor: monitoring.NewMonitor(db.DB, cfg.Telegram),
+		OIDC:    oidc.NewFromConfig(cfg.OIDC),
+		Sandbox: sandbox.NewFromConfig(cfg.Sandbox),
+
+		Registration: cfg.Registration.Mode,
+
+		Blobs:              blobs,
+		AttachmentMaxBytes: int64(cfg.Attachments.MaxMB) << 20,
+
+		SecureCookies: cfg.Server.SecureCookies,
 	}
 }
 
 func (h *Handler) RegisterRoutes(r *gin.Engine) {
+	// CSRF для всех изменяющих запросов
+	r.Use(h.CSRFMiddleware())
+	
 	// Главная
 	r.GET("/", h.Index)
 	
@@ -34,21 +71,113 @@ func (h *Handler) RegisterRoutes(r *gin.Engine) {
 	r.GET("/api/topics", h.GetTopics)
 	r.GET("/api/topics/:slug", h.GetTopicAPI)
 	r.GET("/api/topics/:slug/labs", h.GetLabsAPI)
+	r.GET("/api/topics/:slug/export", h.ExportTopic)
+	r.GET("/api/labs", h.ListLabs)
 	r.GET("/api/labs/:topic/:lab", h.GetLabAPI)
-	r.POST("/api/labs", h.AuthMiddleware(), h.CreateLab)
-	r.PUT("/api/labs/:id", h.AuthMiddleware(), h.UpdateLab)
-	r.DELETE("/api/labs/:id", h.AuthMiddleware(), h.DeleteLab)
+	r.GET("/api/labs/:topic/:lab/script.sh", h.GetLabScript)
+	r.POST("/api/labs", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.CreateLab)
+	r.PUT("/api/labs/:id", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.UpdateLab)
+	r.DELETE("/api/labs/:id", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.DeleteLab)
+	
+	// Ревью и публикация
+	r.GET("/api/reviews", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.GetLabReviews)
+	r.POST("/api/labs/:id/submit", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.SubmitLab)
+	r.POST("/api/labs/:id/approve", h.AuthMiddleware(), h.RequireRole(models.RoleReviewer), h.ApproveLab)
+	r.POST("/api/labs/:id/request-changes", h.AuthMiddleware(), h.RequireRole(models.RoleReviewer), h.RequestChanges)
+	r.POST("/api/labs/:id/archive", h.AuthMiddleware(), h.RequireRole(models.RoleReviewer), h.ArchiveLab)
+	r.POST("/api/labs/:id/unarchive", h.AuthMiddleware(), h.RequireRole(models.RoleReviewer), h.UnarchiveLab)
+	
+	// Теги
+	r.GET("/api/tags", h.GetTags)
+	r.GET("/api/tags/:tag/labs", h.GetTagLabs)
+	r.POST("/api/tags", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.CreateTag)
+	r.PUT("/api/tags/:id", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.UpdateTag)
+	r.DELETE("/api/tags/:id", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.DeleteTag)
+	
+	// Учебные маршруты
+	r.GET("/api/paths", h.GetPaths)
+	r.GET("/api/paths/:slug", h.GetPathAPI)
+	r.POST("/api/paths", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.CreatePath)
+	r.PUT("/api/paths/:id", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.UpdatePath)
+	r.DELETE("/api/paths/:id", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.DeletePath)
+	
+	// Прогресс прохождения
+	r.POST("/api/labs/:id/progress", h.AuthMiddleware(), h.MarkLabProgress)
+	r.DELETE("/api/labs/:id/progress", h.AuthMiddleware(), h.UnmarkLabProgress)
+	r.GET("/api/me/progress", h.AuthMiddleware(), h.GetMyProgress)
+	r.GET("/api/admin/progress", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.ProgressReport)
+	
+	// Комментарии
+	r.GET("/api/comments", h.GetComments)
+	r.POST("/api/labs/:id/comments", h.AuthMiddleware(), h.CreateComment)
+	r.PUT("/api/comments/:id", h.AuthMiddleware(), h.UpdateComment)
+	r.DELETE("/api/comments/:id", h.AuthMiddleware(), h.DeleteComment)
+	r.POST("/api/comments/:id/hide", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.HideComment)
+	r.POST("/api/comments/:id/unhide", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.UnhideComment)
+	
+	// Песочница с терминалом (WebSocket)
+	r.GET("/api/sandbox/terminal", h.AuthMiddleware(), h.SandboxTerminal)
+	
+	// Автоматическая проверка лаб в песочнице
+	r.POST("/api/labs/:id/verify", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.VerifyLab)
+	r.GET("/api/checks", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.GetLabChecks)
+	
+	// Вложения лаб
+	r.POST("/api/labs/:id/attachments", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.UploadAttachment)
+	r.DELETE("/api/labs/:id/attachments/:attachment_id", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.DeleteAttachment)
+	r.GET("/api/attachments", h.GetAttachments)
+	r.GET("/attachments/:id/:filename", h.ServeAttachment)
+	
+	// Закладки и подборки
+	r.GET("/api/me/bookmarks", h.AuthMiddleware(), h.GetBookmarks)
+	r.POST("/api/me/bookmarks", h.AuthMiddleware(), h.AddBookmark)
+	r.DELETE("/api/me/bookmarks/:lab_id", h.AuthMiddleware(), h.RemoveBookmark)
+	r.GET("/api/me/collections", h.AuthMiddleware(), h.GetCollections)
+	r.POST("/api/me/collections", h.AuthMiddleware(), h.CreateCollection)
+	r.PUT("/api/me/collections/:id", h.AuthMiddleware(), h.UpdateCollection)
+	r.DELETE("/api/me/collections/:id", h.AuthMiddleware(), h.DeleteCollection)
+	r.POST("/api/me/collections/:id/share", h.AuthMiddleware(), h.ShareCollection)
+	r.DELETE("/api/me/collections/:id/share", h.AuthMiddleware(), h.UnshareCollection)
+	r.GET("/api/collections/:token", h.GetSharedCollection)
+	
+	// Оценки
+	r.PUT("/api/labs/:id/rating", h.AuthMiddleware(), h.RateLab)
+	r.DELETE("/api/labs/:id/rating", h.AuthMiddleware(), h.UnrateLab)
+	
+	// Сообщения о проблемах
+	r.POST("/api/labs/:id/reports", h.AuthMiddleware(), h.CreateReport)
+	r.GET("/api/reports", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.GetReports)
+	r.PUT("/api/reports/:id", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.UpdateReport)
+	
+	// Инвайт-коды для регистрации
+	r.GET("/api/invites", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.GetInvites)
+	r.POST("/api/invites", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.CreateInvite)
+	r.DELETE("/api/invites/:code", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.DeleteInvite)
+	
+	// Корзина
+	r.GET("/api/trash", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.GetTrash)
+	r.POST("/api/labs/:id/restore", h.AuthMiddleware(), h.RequireRole(models.RoleEditor), h.RestoreLab)
+	r.DELETE("/api/trash/:id", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.PurgeLab)
 	r.GET("/api/metrics", h.GetMetrics)
+	r.GET("/api/audit", h.AuthMiddleware(), h.RequireRole(models.RoleAdmin), h.GetAudit)
 	
 	// Auth API
 	r.POST("/api/auth/login", h.Login)
+	r.POST("/api/auth/register", h.Register)
 	r.POST("/api/auth/logout", h.Logout)
 	r.GET("/api/auth/check", h.CheckAuth)
 	
+	// SSO (OpenID Connect)
+	r.GET("/auth/oidc/login", h.OIDCLogin)
+	r.GET("/auth/oidc/callback", h.OIDCCallback)
+	
 	// HTML страницы
 	r.GET("/topic/:slug", h.TopicPage)
 	r.GET("/lab/:topic/:lab", h.LabPage)
+	r.GET("/path/:slug", h.PathPage)
 	r.GET("/login", h.LoginPage)
+	r.GET("/register", h.RegisterPage)
+	r.GET("/c/:token", h.CollectionPage)
 	
 	// Health
 	r.GET("/health", h.HealthCheck)
@@ -59,9 +188,18 @@ func (h *Handler) RegisterRoutes(r *gin.Engine) {
 
 func (h *Handler) Index(c *gin.Context) {
 	topics, _ := h.DB.GetTopics()
+	paths, _ := h.DB.GetPaths()
+	// Самые полезные за месяц - по оценкам последних 30 дней
+	topLabs, err := h.DB.GetTopRatedLabs(time.Now().AddDate(0, 0, -30), 6)
+	if err != nil {
+		log.Println("ERROR Index top labs:", err)
+	}
 	c.HTML(http.StatusOK, "index.html", gin.H{
-		"title":  "DevOps Manual",
-		"topics": topics,
+		"title":    "DevOps Manual",
+		"topics":   topics,
+		"paths":    paths,
+		"top_labs": topLabs,
+		"csrf":     csrfToken(c),
 	})
 }
 
@@ -88,7 +226,7 @@ func (h *Handler) GetTopicAPI(c *gin.Context) {
 
 func (h *Handler) GetLabsAPI(c *gin.Context) {
 	slug := c.Param("slug")
-	labs, err := h.DB.GetLabsByTopicSlug(slug)
+	labs, err := h.DB.GetLabsByTopicSlug(slug, !canEdit(h.viewer(c)))
 	if err != nil {
 		log.Println("ERROR GetLabsAPI:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -100,15 +238,45 @@ func (h *Handler) GetLabsAPI(c *gin.Context) {
 func (h *Handler) GetLabAPI(c *gin.Context) {
 	topicSlug := c.Param("topic")
 	labSlug := c.Param("lab")
-	lab, err := h.DB.GetLabBySlug(topicSlug, labSlug)
+	lab, err := h.DB.GetLabBySlug(topicSlug, labSlug, !canEdit(h.viewer(c)))
 	if err != nil {
 		log.Println("ERROR GetLabAPI:", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
 		return
 	}
+
+	// ?vars={"namespace": "prod"} - команды и текст с подставленными значениями
+	if raw, ok := c.GetQuery("vars"); ok {
+		values, err := labvars.ParseValues(raw)
+		if err == nil {
+			values, err = labvars.Values(lab.Variables, values)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		lab = labvars.RenderLab(lab, values)
+	}
 	c.JSON(http.StatusOK, lab)
 }
 
+// renderedLab подставляет в лабу значения из ?vars=, при ошибке - значения по умолчанию
+func renderedLab(c *gin.Context, lab *models.Lab) *models.Lab {
+	return labvars.RenderLab(lab, labValues(c, lab))
+}
+
+// labValues - значения переменных из ?vars=, при ошибке - значения по умолчанию
+func labValues(c *gin.Context, lab *models.Lab) map[string]string {
+	values, err := labvars.ParseValues(c.Query("vars"))
+	if err == nil {
+		values, err = labvars.Values(lab.Variables, values)
+	}
+	if err != nil {
+		values, _ = labvars.Values(lab.Variables, nil)
+	}
+	return values
+}
+
 func (h *Handler) CreateLab(c *gin.Context) {
 	log.Println("DEBUG: CreateLab called")
 	
@@ -120,15 +288,30 @@ func (h *Handler) CreateLab(c *gin.Context) {
 	}
 	
 	log.Printf("DEBUG: Lab data: %+v", lab)
+	lab.AuthorID = c.GetInt("user_id")
+
+	if err := h.validateRequires(lab.Requires); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := labcheck.Validate(&lab); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	// Теги и пререквизиты сохраняются в одной транзакции с лабой
 	if err := h.DB.CreateLab(&lab); err != nil {
 		log.Println("ERROR: Failed to create lab:", err)
+		if errors.Is(err, database.ErrPrerequisiteCycle) || errors.Is(err, database.ErrUnknownLab) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	log.Println("DEBUG: Lab created successfully, ID:", lab.ID)
-	h.Monitor.SendAlert(fmt.Sprintf("📝 Новая лаба создана: %s", lab.Title))
+	h.audit(c, "lab.create", "lab", lab.ID, nil, lab)
 	c.JSON(http.StatusCreated, lab)
 }
 
@@ -142,24 +325,94 @@ func (h *Handler) UpdateLab(c *gin.Context) {
 
 	id, _ := strconv.Atoi(c.Param("id"))
 	lab.ID = id
-	
+
+	if err := labcheck.Expectations(&lab); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := labcheck.DestructiveSteps(&lab); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.DB.GetLabByID(id)
+	if err != nil {
+		log.Println("ERROR UpdateLab:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
+		return
+	}
+
+	// Статус из запроса не принимается - он меняется только переходами workflow
+	lab.Status = ""
+
+	// Без clear_schedule отсутствующие даты остаются прежними - сверяем итоговое расписание
+	publishAt, unpublishAt := lab.PublishAt, lab.UnpublishAt
+	if !lab.ClearSchedule {
+		if publishAt == nil {
+			publishAt = before.PublishAt
+		}
+		if unpublishAt == nil {
+			unpublishAt = before.UnpublishAt
+		}
+	}
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unpublish_at must be after publish_at"})
+		return
+	}
+
+	// Без поля variables в запросе плейсхолдеры сверяем с сохранёнными переменными
+	vars := lab
+	if vars.Variables == nil {
+		vars.Variables = before.Variables
+	}
+	if err := labvars.Validate(&vars); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Правка опубликованной или запланированной лабы от редактора ждёт ревью
+	// отдельно: читатели видят прежнюю версию, статус и расписание не меняются.
+	// Ревьюер и админ правят опубликованное напрямую.
+	if isLive(before) && models.RoleRank(currentUser(c).Role) < models.RoleRank(models.RoleReviewer) {
+		h.submitRevision(c, before, mergeRevision(before, &lab, publishAt, unpublishAt))
+		return
+	}
+
+	// Пререквизиты и теги (если пришли в запросе) сохраняются вместе с лабой
 	if err := h.DB.UpdateLab(&lab); err != nil {
 		log.Println("ERROR UpdateLab:", err)
+		if errors.Is(err, database.ErrPrerequisiteCycle) || errors.Is(err, database.ErrUnknownLab) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	lab.Status = before.Status
+
+	after, _ := h.DB.GetLabByID(id)
+	h.audit(c, "lab.update", "lab", id, before, after)
 	c.JSON(http.StatusOK, lab)
 }
 
 func (h *Handler) DeleteLab(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+
+	before, err := h.DB.GetLabByID(id)
+	if err != nil {
+		log.Println("ERROR DeleteLab:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
+		return
+	}
+
 	if err := h.DB.DeleteLab(id); err != nil {
 		log.Println("ERROR DeleteLab:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+	h.audit(c, "lab.delete", "lab", id, before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Moved to trash"})
 }
 
 // HTML Pages
@@ -172,12 +425,27 @@ func (h *Handler) TopicPage(c *gin.Context) {
 		return
 	}
 
-	labs, _ := h.DB.GetLabsByTopicSlug(slug)
+	q, err := h.labQuery(c)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "404.html", nil)
+		return
+	}
+	q.TopicSlug = slug
+	labs, next, total, err := h.DB.ListLabs(q)
+	if err != nil {
+		log.Println("ERROR TopicPage:", err)
+	}
 	
 	c.HTML(http.StatusOK, "topic/topic.html", gin.H{
 		"title": topic.Title,
 		"topic": topic,
 		"labs":  labs,
+		"total": total,
+		"next":  next,
+		"first": c.Query("cursor") != "",
+		"sort":  c.DefaultQuery("sort", "-created_at"),
+		"tag":   c.Query("tag"),
+		"csrf":  csrfToken(c),
 	})
 }
 
@@ -185,22 +453,62 @@ func (h *Handler) LabPage(c *gin.Context) {
 	topicSlug := c.Param("topic")
 	labSlug := c.Param("lab")
 	
-	lab, err := h.DB.GetLabBySlug(topicSlug, labSlug)
+	lab, err := h.DB.GetLabBySlug(topicSlug, labSlug, !canEdit(h.viewer(c)))
 	if err != nil {
 		log.Println("ERROR LabPage:", err)
 		c.HTML(http.StatusNotFound, "404.html", nil)
 		return
 	}
 
-	c.HTML(http.StatusOK, "lab/lab.html", gin.H{
-		"title": lab.Title,
-		"lab":   lab,
-	})
+	// lab - исходник с плейсхолдерами (для редактора), view и content - для чтения
+	values := labValues(c, lab)
+	data := gin.H{
+		"title":   lab.Title,
+		"lab":     lab,
+		"view":    labvars.RenderLab(lab, values),
+		"content": template.HTML(labvars.RenderContent(lab, values)),
+		"csrf":    csrfToken(c),
+	}
+
+	if v := h.viewer(c); v != nil {
+		data["my_rating"], _ = h.DB.GetUserRating(v.ID, lab.ID)
+		data["bookmarked"], _ = h.DB.IsBookmarked(v.ID, lab.ID)
+		data["sandbox"] = h.Sandbox != nil
+	}
+
+	// Редакторам - ожидающая ревью правка опубликованной лабы для формы
+	if canEdit(h.viewer(c)) {
+		if rev, err := h.DB.GetLabRevision(lab.ID); err != nil {
+			log.Println("ERROR LabPage revision:", err)
+		} else if rev != nil {
+			data["revision"] = rev
+		}
+	}
+
+	if n, err := h.DB.CountOpenReports(lab.ID); err == nil {
+		data["open_reports"] = n
+	} else {
+		log.Println("ERROR LabPage reports:", err)
+	}
+
+	// Навигация по учебному маршруту: /lab/:topic/:lab?path=slug
+	if pathSlug := c.Query("path"); pathSlug != "" {
+		if path, err := h.DB.GetPathBySlug(pathSlug, !canEdit(h.viewer(c))); err == nil {
+			data["path"] = path
+			data["prev"], data["next"] = pathNeighbours(path, lab.ID)
+		}
+	}
+
+	c.HTML(http.StatusOK, "lab/lab.html", data)
 }
 
 func (h *Handler) LoginPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "auth/login.html", gin.H{
 		"title": "Login",
+		"sso":   h.OIDC != nil,
+		"csrf":  csrfToken(c),
+
+		"register": h.Registration != RegistrationClosed,
 	})
 }
 
@@ -220,31 +528,38 @@ func (h *Handler) Login(c *gin.Context) {
 	user, err := h.DB.GetUserByUsername(req.Username)
 	if err != nil {
 		log.Println("ERROR Login user not found:", err)
+		h.auditAs(c, nil, "auth.login_failed", "user", nil, nil, gin.H{"username": req.Username})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		log.Println("ERROR Login password mismatch")
+		h.auditAs(c, user, "auth.login_failed", "user", user.ID, nil, gin.H{"username": req.Username})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	token := h.DB.CreateSession(user.ID)
-	c.SetCookie("session", token, 86400, "/", "", false, true)
+	h.auditAs(c, user, "auth.login", "user", user.ID, nil, nil)
+	h.setCookie(c, "session", token, 86400, "/")
 	c.JSON(http.StatusOK, gin.H{"user": user.Username, "is_admin": user.IsAdmin})
 }
 
 func (h *Handler) Logout(c *gin.Context) {
-	token, _ := c.Cookie("session")
+	token := sessionToken(c)
+	if session := h.DB.GetSession(token); session != nil {
+		user, _ := h.DB.GetUserByID(session.UserID)
+		h.auditAs(c, user, "auth.logout", "user", session.UserID, nil, nil)
+	}
 	h.DB.DeleteSession(token)
-	c.SetCookie("session", "", -1, "/", "", false, true)
+	h.setCookie(c, "session", "", -1, "/")
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 }
 
 func (h *Handler) CheckAuth(c *gin.Context) {
-	token, err := c.Cookie("session")
-	if err != nil {
+	token := sessionToken(c)
+	if token == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"authenticated": false})
 		return
 	}
@@ -255,14 +570,26 @@ func (h *Handler) CheckAuth(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"authenticated": true})
+	user, err := h.DB.GetUserByID(session.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"authenticated": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authenticated": true,
+		"user":          user.Username,
+		"role":          user.Role,
+		"can_edit":      canEdit(user),
+		"can_review":    models.RoleRank(user.Role) >= models.RoleRank(models.RoleReviewer),
+	})
 }
 
 func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token, err := c.Cookie("session")
-		if err != nil {
-			log.Println("ERROR AuthMiddleware: no cookie")
+		token := sessionToken(c)
+		if token == "" {
+			log.Println("ERROR AuthMiddleware: no session")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
@@ -276,11 +603,61 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		user, err := h.DB.GetUserByID(session.UserID)
+		if err != nil {
+			log.Println("ERROR AuthMiddleware: user lookup:", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", session.UserID)
+		c.Set("user", user)
 		c.Next()
 	}
 }
 
+// RequireRole пропускает только пользователей с ролью не ниже указанной.
+// Ставится после AuthMiddleware.
+func (h *Handler) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentUser(c)
+		if user == nil || models.RoleRank(user.Role) < models.RoleRank(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentUser возвращает пользователя, положенного в контекст AuthMiddleware
+func currentUser(c *gin.Context) *models.User {
+	if v, ok := c.Get("user"); ok {
+		if u, ok := v.(*models.User); ok {
+			return u
+		}
+	}
+	return nil
+}
+
+// sessionToken берёт токен из Authorization: Bearer, иначе из cookie.
+// При наличии Bearer cookie игнорируется - такие запросы освобождены от CSRF.
+func sessionToken(c *gin.Context) string {
+	if token := bearerToken(c); token != "" {
+		return token
+	}
+	token, _ := c.Cookie("session")
+	return token
+}
+
+// setCookie ставит HttpOnly cookie с SameSite=Lax; Secure - по настройке
+// server.secure_cookies. Lax нужен, чтобы cookie пришла при редиректе с IdP.
+func (h *Handler) setCookie(c *gin.Context, name, value string, maxAge int, path string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, path, "", h.SecureCookies, true)
+}
+
 func (h *Handler) GetMetrics(c *gin.Context) {
 	metrics, err := h.Monitor.GetMetrics()
 	if err != nil {
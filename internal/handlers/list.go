@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListLabs - GET /api/labs?topic=&difficulty=&author=&tag=&sort=-created_at&limit=20&cursor=
+func (h *Handler) ListLabs(c *gin.Context) {
+	q, err := h.labQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	labs, next, total, err := h.DB.ListLabs(q)
+	if err != nil {
+		log.Println("ERROR ListLabs:", err)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("Link", paginationLinks(c.Request.URL, next))
+	c.JSON(http.StatusOK, labs)
+}
+
+// labQuery собирает параметры выборки из query string.
+// sort с минусом означает обратный порядок: sort=-updated_at
+func (h *Handler) labQuery(c *gin.Context) (models.LabQuery, error) {
+	q := models.LabQuery{
+		TopicSlug:     c.Query("topic"),
+		Difficulty:    c.Query("difficulty"),
+		Author:        c.Query("author"),
+		Tag:           c.Query("tag"),
+		Cursor:        c.Query("cursor"),
+		PublishedOnly: !canEdit(h.viewer(c)),
+	}
+
+	sort := c.DefaultQuery("sort", "-created_at")
+	if strings.HasPrefix(sort, "-") {
+		q.Desc = true
+		sort = sort[1:]
+	}
+	if !database.IsValidLabSort(sort) {
+		return q, fmt.Errorf("unsupported sort field %q", sort)
+	}
+	q.Sort = sort
+
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 100 {
+			return q, fmt.Errorf("limit must be between 1 and 100")
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// paginationLinks формирует заголовок Link (RFC 8288) с rel="first" и rel="next"
+func paginationLinks(u *url.URL, next string) string {
+	link := func(cursor, rel string) string {
+		v := u.Query()
+		v.Del("cursor")
+		if cursor != "" {
+			v.Set("cursor", cursor)
+		}
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, v.Encode(), rel)
+	}
+
+	links := []string{link("", "first")}
+	if next != "" {
+		links = append(links, link(next, "next"))
+	}
+	return strings.Join(links, ", ")
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"devops-manual/internal/database"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCLogin перенаправляет на IdP (authorization code + PKCE)
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	if h.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SSO is not configured"})
+		return
+	}
+
+	authURL, state, err := h.OIDC.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		log.Println("ERROR OIDCLogin:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	// state привязываем к браузеру, чтобы нельзя было подсунуть чужой callback.
+	h.setCookie(c, "oidc_state", state, 600, "/auth/oidc")
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback принимает code от IdP, создаёт пользователя при первом входе и открывает сессию
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if h.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SSO is not configured"})
+		return
+	}
+
+	if e := c.Query("error"); e != "" {
+		log.Println("ERROR OIDCCallback: IdP returned", e, c.Query("error_description"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "SSO login failed"})
+		return
+	}
+
+	state := c.Query("state")
+	cookieState, _ := c.Cookie("oidc_state")
+	h.setCookie(c, "oidc_state", "", -1, "/auth/oidc")
+	if state == "" || state != cookieState {
+		log.Println("ERROR OIDCCallback: state mismatch")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
+		return
+	}
+
+	claims, err := h.OIDC.Exchange(c.Request.Context(), state, c.Query("code"))
+	if err != nil {
+		log.Println("ERROR OIDCCallback:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "SSO login failed"})
+		return
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Email
+	}
+	if username == "" {
+		username = claims.Subject
+	}
+
+	user, created, err := h.DB.UpsertOIDCUser(claims.Subject, username, h.OIDC.Role(claims.Groups))
+	if err != nil {
+		log.Println("ERROR OIDCCallback user:", err)
+		if errors.Is(err, database.ErrOIDCUsernameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username is already taken"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	if created {
+		h.auditAs(c, user, "user.create", "user", user.ID, nil, user)
+	}
+
+	token := h.DB.CreateSession(user.ID)
+	h.auditAs(c, user, "auth.login", "user", user.ID, nil, gin.H{"method": "oidc"})
+	h.setCookie(c, "session", token, 86400, "/")
+	c.Redirect(http.StatusFound, "/")
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetPaths(c *gin.Context) {
+	paths, err := h.DB.GetPaths()
+	if err != nil {
+		log.Println("ERROR GetPaths:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, paths)
+}
+
+func (h *Handler) GetPathAPI(c *gin.Context) {
+	path, err := h.DB.GetPathBySlug(c.Param("slug"), !canEdit(h.viewer(c)))
+	if err != nil {
+		log.Println("ERROR GetPathAPI:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Path not found"})
+		return
+	}
+	c.JSON(http.StatusOK, path)
+}
+
+func (h *Handler) CreatePath(c *gin.Context) {
+	var path models.Path
+	if err := c.ShouldBindJSON(&path); err != nil || path.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+
+	if err := h.DB.CreatePath(&path); err != nil {
+		log.Println("ERROR CreatePath:", err)
+		h.pathError(c, err)
+		return
+	}
+
+	h.audit(c, "path.create", "path", path.ID, nil, path)
+	c.JSON(http.StatusCreated, path)
+}
+
+func (h *Handler) UpdatePath(c *gin.Context) {
+	var path models.Path
+	if err := c.ShouldBindJSON(&path); err != nil || path.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	path.ID, _ = strconv.Atoi(c.Param("id"))
+
+	before, err := h.DB.GetPathByID(path.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Path not found"})
+		return
+	}
+
+	if err := h.DB.UpdatePath(&path); err != nil {
+		log.Println("ERROR UpdatePath:", err)
+		h.pathError(c, err)
+		return
+	}
+
+	after, _ := h.DB.GetPathByID(path.ID)
+	h.audit(c, "path.update", "path", path.ID, before, after)
+	c.JSON(http.StatusOK, after)
+}
+
+func (h *Handler) DeletePath(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	before, err := h.DB.GetPathByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Path not found"})
+		return
+	}
+
+	if err := h.DB.DeletePath(id); err != nil && err != sql.ErrNoRows {
+		log.Println("ERROR DeletePath:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "path.delete", "path", id, before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+func (h *Handler) pathError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrUnknownLab):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case database.IsUniqueViolation(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "Path with this title already exists"})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Path not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// PathPage - страница маршрута
+func (h *Handler) PathPage(c *gin.Context) {
+	path, err := h.DB.GetPathBySlug(c.Param("slug"), !canEdit(h.viewer(c)))
+	if err != nil {
+		log.Println("ERROR PathPage:", err)
+		c.HTML(http.StatusNotFound, "404.html", nil)
+		return
+	}
+
+	c.HTML(http.StatusOK, "path/path.html", gin.H{
+		"title": path.Title,
+		"path":  path,
+		"csrf":  csrfToken(c),
+	})
+}
+
+// pathNeighbours возвращает соседние лабы маршрута для навигации со страницы лабы
+func pathNeighbours(path *models.Path, labID int) (prev, next *models.Lab) {
+	var labs []*models.Lab
+	for _, item := range path.Items {
+		if item.Lab != nil {
+			labs = append(labs, item.Lab)
+		}
+	}
+	for i, l := range labs {
+		if l.ID != labID {
+			continue
+		}
+		if i > 0 {
+			prev = labs[i-1]
+		}
+		if i < len(labs)-1 {
+			next = labs[i+1]
+		}
+		break
+	}
+	return prev, next
+}
+
+// validateRequires проверяет, что все пререквизиты новой лабы существуют
+func (h *Handler) validateRequires(refs []string) error {
+	for _, ref := range refs {
+		topicSlug, labSlug, ok := strings.Cut(ref, "/")
+		if !ok || topicSlug == "" || labSlug == "" {
+			return errors.New("invalid prerequisite " + strconv.Quote(ref) + " (expected topic/lab)")
+		}
+		if _, err := h.DB.GetLabBySlug(topicSlug, labSlug, false); err != nil {
+			return errors.New("unknown prerequisite " + strconv.Quote(ref))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MarkLabProgress - POST /api/labs/:id/progress {"step": 2}; без step отмечается вся лаба
+func (h *Handler) MarkLabProgress(c *gin.Context) {
+	var req struct {
+		Step *int `json:"step"`
+	}
+	c.ShouldBindJSON(&req)
+
+	labID, ok := h.progressLab(c, req.Step)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.MarkProgress(c.GetInt("user_id"), labID, req.Step); err != nil {
+		log.Println("ERROR MarkLabProgress:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Completed"})
+}
+
+// UnmarkLabProgress - DELETE /api/labs/:id/progress?step=2
+func (h *Handler) UnmarkLabProgress(c *gin.Context) {
+	var step *int
+	if s := c.Query("step"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step"})
+			return
+		}
+		step = &n
+	}
+
+	labID, ok := h.progressLab(c, step)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.UnmarkProgress(c.GetInt("user_id"), labID, step); err != nil {
+		log.Println("ERROR UnmarkLabProgress:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Incomplete"})
+}
+
+// progressLab проверяет лабу и номер шага; при ошибке ответ уже отправлен
+func (h *Handler) progressLab(c *gin.Context, step *int) (int, bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	lab, ok := h.visibleLab(c, id, currentUser(c))
+	if !ok {
+		return 0, false
+	}
+	if step != nil && (*step < 0 || *step >= len(lab.Commands)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("step must be between 0 and %d", len(lab.Commands)-1)})
+		return 0, false
+	}
+	return lab.ID, true
+}
+
+// GetMyProgress - GET /api/me/progress?lab_id=: отметки и процент прохождения по темам
+func (h *Handler) GetMyProgress(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	labID, _ := strconv.Atoi(c.Query("lab_id"))
+
+	marks, err := h.DB.GetUserProgress(userID, labID)
+	if err != nil {
+		log.Println("ERROR GetMyProgress:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	topics, err := h.DB.GetTopicProgress(userID)
+	if err != nil {
+		log.Println("ERROR GetMyProgress:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range topics {
+		topics[i].UserID = 0
+		topics[i].Username = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"labs":   marks,
+		"topics": topics,
+	})
+}
+
+// ProgressReport - GET /api/admin/progress?format=csv: прохождение по пользователям и темам
+func (h *Handler) ProgressReport(c *gin.Context) {
+	report, err := h.DB.GetTopicProgress(0)
+	if err != nil {
+		log.Println("ERROR ProgressReport:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="progress.csv"`)
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"user_id", "username", "topic", "completed", "total", "percent"})
+	for _, r := range report {
+		w.Write([]string{
+			strconv.Itoa(r.UserID),
+			csvText(r.Username),
+			csvText(r.TopicSlug),
+			strconv.Itoa(r.Completed),
+			strconv.Itoa(r.Total),
+			strconv.FormatFloat(r.Percent, 'f', 1, 64),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Println("ERROR ProgressReport csv:", err)
+	}
+}
+
+// csvText защищает текстовую ячейку CSV от выполнения как формулы в табличном
+// редакторе: username OIDC пользователей приходит от IdP без проверки
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import "testing"
+
+func TestCSVText(t *testing.T) {
+	tests := map[string]string{
+		"ivan":              "ivan",
+		"":                  "",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+7 999":            "'+7 999",
+		"-1+1":              "'-1+1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\t=1":              "'\t=1",
+		"ivan@example.com":  "ivan@example.com",
+		"docker-basics":     "docker-basics",
+	}
+	for in, want := range tests {
+		if got := csvText(in); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLab - PUT /api/labs/:id/rating {"score": 1..5}
+func (h *Handler) RateLab(c *gin.Context) {
+	var req struct {
+		Score int `json:"score"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Score < 1 || req.Score > 5 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "score must be between 1 and 5"})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	lab, ok := h.visibleLab(c, id, currentUser(c))
+	if !ok {
+		return
+	}
+
+	if err := h.DB.RateLab(c.GetInt("user_id"), lab.ID, req.Score); err != nil {
+		log.Println("ERROR RateLab:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.ratingResponse(c, lab.ID, req.Score)
+}
+
+// UnrateLab - DELETE /api/labs/:id/rating
+func (h *Handler) UnrateLab(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	lab, ok := h.visibleLab(c, id, currentUser(c))
+	if !ok {
+		return
+	}
+
+	if err := h.DB.UnrateLab(c.GetInt("user_id"), lab.ID); err != nil {
+		log.Println("ERROR UnrateLab:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.ratingResponse(c, lab.ID, 0)
+}
+
+// ratingResponse отдаёт обновлённые агрегаты лабы и оценку пользователя
+func (h *Handler) ratingResponse(c *gin.Context, labID, score int) {
+	lab, err := h.DB.GetLabByID(labID)
+	if err != nil {
+		log.Println("ERROR ratingResponse:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rating":       lab.Rating,
+		"rating_count": lab.RatingCount,
+		"my_rating":    score,
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Режимы саморегистрации (REGISTRATION_MODE)
+const (
+	RegistrationClosed = "closed" // только админ создаёт учётки
+	RegistrationOpen   = "open"   // любой может зарегистрироваться
+	RegistrationInvite = "invite" // нужен инвайт-код от админа
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{2,31}$`)
+
+// RegisterPage - форма регистрации, при выключенной регистрации 404
+func (h *Handler) RegisterPage(c *gin.Context) {
+	if h.Registration == RegistrationClosed {
+		c.String(http.StatusNotFound, "Registration is disabled")
+		return
+	}
+	c.HTML(http.StatusOK, "auth/register.html", gin.H{
+		"title":  "Register",
+		"invite": h.Registration == RegistrationInvite,
+		"code":   c.Query("invite"),
+		"csrf":   csrfToken(c),
+	})
+}
+
+// Register - POST /api/auth/register: создаёт читателя и сразу открывает сессию
+func (h *Handler) Register(c *gin.Context) {
+	if h.Registration == RegistrationClosed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is disabled"})
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Invite   string `json:"invite"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+	req.Invite = strings.TrimSpace(req.Invite)
+
+	if msg := validateRegistration(req.Username, req.Email, req.Password); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if h.Registration == RegistrationInvite && req.Invite == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invite code is required"})
+		return
+	}
+	if h.Registration == RegistrationOpen {
+		req.Invite = ""
+	}
+
+	user, err := h.DB.RegisterUser(req.Username, req.Email, req.Password, req.Invite)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidInvite):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or used invite code"})
+		case database.IsUniqueViolation(err):
+			c.JSON(http.StatusConflict, gin.H{"error": "Username or email is already taken"})
+		default:
+			log.Println("ERROR Register:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.auditAs(c, user, "user.register", "user", user.ID, nil, gin.H{"username": user.Username, "invite": req.Invite != ""})
+
+	token := h.DB.CreateSession(user.ID)
+	h.setCookie(c, "session", token, 86400, "/")
+	c.JSON(http.StatusCreated, gin.H{"user": user.Username, "role": user.Role})
+}
+
+// validateRegistration возвращает текст ошибки или пустую строку
+func validateRegistration(username, email, password string) string {
+	if !usernamePattern.MatchString(username) {
+		return "Username must be 3-32 characters: letters, digits, '.', '_' or '-'"
+	}
+	addr, err := mail.ParseAddress(email)
+	// ParseAddress принимает и "Имя <addr>", требуем голый адрес
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return "Invalid email"
+	}
+	if len(password) < models.MinPasswordLength {
+		return fmt.Sprintf("Password must be at least %d characters", models.MinPasswordLength)
+	}
+	if len(password) > models.MaxPasswordBytes {
+		return fmt.Sprintf("Password must be at most %d bytes", models.MaxPasswordBytes)
+	}
+	return ""
+}
+
+// GetInvites - GET /api/invites (админ)
+func (h *Handler) GetInvites(c *gin.Context) {
+	invites, err := h.DB.GetInviteCodes()
+	if err != nil {
+		log.Println("ERROR GetInvites:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+// CreateInvite - POST /api/invites {"expires_in_days": 7}; 0 - бессрочный код
+func (h *Handler) CreateInvite(c *gin.Context) {
+	var req struct {
+		ExpiresInDays int `json:"expires_in_days"`
+	}
+	c.ShouldBindJSON(&req)
+	if req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must not be negative"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &t
+	}
+
+	invite, err := h.DB.CreateInviteCode(c.GetInt("user_id"), expiresAt)
+	if err != nil {
+		log.Println("ERROR CreateInvite:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "invite.create", "invite", invite.Code, nil, invite)
+	c.JSON(http.StatusCreated, invite)
+}
+
+// DeleteInvite - DELETE /api/invites/:code, отзывает неиспользованный код
+func (h *Handler) DeleteInvite(c *gin.Context) {
+	code := c.Param("code")
+	if err := h.DB.DeleteInviteCode(code); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found or already used"})
+			return
+		}
+		log.Println("ERROR DeleteInvite:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "invite.delete", "invite", code, nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
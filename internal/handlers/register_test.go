@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRegistration(t *testing.T) {
+	tests := []struct {
+		name, username, email, password string
+		want                            string // "" - данные корректны
+	}{
+		{"valid", "ivan.petrov", "ivan@example.com", "correct horse", ""},
+		{"short username", "iv", "ivan@example.com", "correct horse", "Username"},
+		{"display name in email", "ivan", "Ivan <ivan@example.com>", "correct horse", "Invalid email"},
+		{"email without domain dot", "ivan", "ivan@localhost", "correct horse", "Invalid email"},
+		{"short password", "ivan", "ivan@example.com", "short", "at least 8"},
+		{"72 bytes", "ivan", "ivan@example.com", strings.Repeat("a", 72), ""},
+		{"73 bytes", "ivan", "ivan@example.com", strings.Repeat("a", 73), "at most 72 bytes"},
+		// 37 кириллических букв - 74 байта в UTF-8
+		{"long in bytes, not runes", "ivan", "ivan@example.com", strings.Repeat("я", 37), "at most 72 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateRegistration(tt.username, tt.email, tt.password)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("validateRegistration = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	reportTypes    = []string{models.ReportCommandFails, models.ReportOutdated, models.ReportTypo}
+	reportStatuses = []string{models.ReportOpen, models.ReportAcknowledged, models.ReportFixed, models.ReportWontFix}
+)
+
+func oneOf(v string, allowed []string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateReport - POST /api/labs/:id/reports {"type": "outdated", "description": "...", "environment": "Ubuntu 24.04"}
+func (h *Handler) CreateReport(c *gin.Context) {
+	var req struct {
+		Type        string `json:"type"`
+		Description string `json:"description"`
+		Environment string `json:"environment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.Description = strings.TrimSpace(req.Description)
+	req.Environment = strings.TrimSpace(req.Environment)
+
+	if !oneOf(req.Type, reportTypes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be one of: " + strings.Join(reportTypes, ", ")})
+		return
+	}
+	if req.Description == "" || len(req.Description) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Description is required (up to 10000 bytes)"})
+		return
+	}
+	if len(req.Environment) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Environment must be at most 500 bytes"})
+		return
+	}
+
+	labID, _ := strconv.Atoi(c.Param("id"))
+	lab, ok := h.visibleLab(c, labID, currentUser(c))
+	if !ok {
+		return
+	}
+
+	report := &models.Report{
+		LabID:       lab.ID,
+		UserID:      c.GetInt("user_id"),
+		Type:        req.Type,
+		Description: req.Description,
+		Environment: req.Environment,
+	}
+	if err := h.DB.CreateReport(report); err != nil {
+		log.Println("ERROR CreateReport:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "report.create", "report", report.ID, nil, report)
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetReports - GET /api/reports?lab_id=&status=&type=&limit=&offset=
+func (h *Handler) GetReports(c *gin.Context) {
+	f := models.ReportFilter{
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+	}
+	f.LabID, _ = strconv.Atoi(c.Query("lab_id"))
+	f.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	f.Limit = database.PageLimit(f.Limit)
+	f.Offset, _ = strconv.Atoi(c.Query("offset"))
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	if f.Status != "" && !oneOf(f.Status, reportStatuses) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of: " + strings.Join(reportStatuses, ", ")})
+		return
+	}
+	if f.Type != "" && !oneOf(f.Type, reportTypes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be one of: " + strings.Join(reportTypes, ", ")})
+		return
+	}
+
+	reports, total, err := h.DB.GetReports(f)
+	if err != nil {
+		log.Println("ERROR GetReports:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+		"total":   total,
+		"limit":   f.Limit,
+		"offset":  f.Offset,
+	})
+}
+
+// UpdateReport - PUT /api/reports/:id {"status": "fixed", "fixed_by_event_id": 42}.
+// Ссылка на правку без статуса переводит сообщение в fixed.
+func (h *Handler) UpdateReport(c *gin.Context) {
+	var req struct {
+		Status         string `json:"status"`
+		FixedByEventID *int64 `json:"fixed_by_event_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Status != "" && !oneOf(req.Status, reportStatuses) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of: " + strings.Join(reportStatuses, ", ")})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	before, err := h.DB.GetReportByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	report := *before
+	if req.FixedByEventID != nil {
+		report.FixedByEventID = req.FixedByEventID
+		if req.Status == "" {
+			req.Status = models.ReportFixed
+		}
+	}
+	if req.Status != "" {
+		report.Status = req.Status
+	}
+
+	if err := h.DB.UpdateReport(&report); err != nil {
+		switch {
+		case errors.Is(err, database.ErrUnknownRevision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err == sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		default:
+			log.Println("ERROR UpdateReport:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	after, _ := h.DB.GetReportByID(id)
+	h.audit(c, "report.update", "report", id, before, after)
+	c.JSON(http.StatusOK, after)
+}
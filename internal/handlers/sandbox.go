@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"devops-manual/internal/models"
+	"devops-manual/internal/sandbox"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// SandboxTerminal - GET /api/sandbox/terminal?lab_id= (WebSocket).
+// Подключает терминал к контейнеру с файлами лабы в /root/lab.
+// GET /api/labs/:id/... занят маршрутом /api/labs/:topic/:lab.
+func (h *Handler) SandboxTerminal(c *gin.Context) {
+	if h.Sandbox == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sandbox is disabled"})
+		return
+	}
+
+	labID, err := strconv.Atoi(c.Query("lab_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lab_id is required"})
+		return
+	}
+	user := currentUser(c)
+	lab, ok := h.visibleLab(c, labID, user)
+	if !ok {
+		return
+	}
+	lab = renderedLab(c, lab)
+
+	server := websocket.Server{
+		// CSRF для GET не проверяется, поэтому чужие сайты отсекаем по Origin
+		Handshake: func(cfg *websocket.Config, req *http.Request) error {
+			origin, err := url.Parse(req.Header.Get("Origin"))
+			if err != nil || origin.Host != req.Host {
+				return errors.New("cross-origin sandbox connection")
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			h.serveTerminal(ws, user.ID, labFiles(lab), func(sess sandbox.Session) {
+				h.audit(c, "sandbox.open", "lab", lab.ID, nil, gin.H{"session": sess.ID()})
+			})
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveTerminal открывает сессию песочницы и связывает её с WebSocket, пока
+// одна из сторон не закроет поток; opened вызывается после запуска контейнера
+func (h *Handler) serveTerminal(ws *websocket.Conn, userID int, files []sandbox.File, opened func(sandbox.Session)) {
+	defer ws.Close()
+	ws.PayloadType = websocket.BinaryFrame
+
+	sess, err := h.Sandbox.Open(ws.Request().Context(), userID, files)
+	if err != nil {
+		log.Println("ERROR SandboxTerminal:", err)
+		fmt.Fprintf(ws, "\r\n[sandbox: %s]\r\n", sandboxError(err))
+		return
+	}
+	defer sess.Close()
+	opened(sess)
+
+	// Сессия закрывается, когда любая из сторон завершила поток
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		io.Copy(ws, sess)
+		cancel()
+	}()
+	go func() {
+		io.Copy(sess, ws)
+		cancel()
+	}()
+	<-ctx.Done()
+}
+
+// sandboxError - понятное пользователю описание ошибки запуска
+func sandboxError(err error) string {
+	switch {
+	case errors.Is(err, sandbox.ErrTooManySessions):
+		return "у вас уже открыт терминал, закройте его в другой вкладке"
+	case errors.Is(err, sandbox.ErrCapacity):
+		return "все песочницы заняты, попробуйте позже"
+	default:
+		return "не удалось запустить контейнер"
+	}
+}
+
+// labFiles - команды лабы для песочницы: скрипт целиком и README с шагами
+func labFiles(lab *models.Lab) []sandbox.File {
+	var script, readme strings.Builder
+	script.WriteString("#!/bin/bash\nset -e\n\n")
+	fmt.Fprintf(&readme, "%s\n\n", lab.Title)
+	for i, cmd := range lab.Commands {
+		script.WriteString(cmd + "\n")
+		fmt.Fprintf(&readme, "%d. %s\n", i+1, cmd)
+	}
+
+	return []sandbox.File{
+		{Path: "/root/lab/commands.sh", Mode: 0755, Data: []byte(script.String())},
+		{Path: "/root/lab/README.txt", Mode: 0644, Data: []byte(readme.String())},
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bufio"
+	"devops-manual/internal/models"
+	"devops-manual/internal/sandbox"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// terminalServer поднимает WebSocket с serveTerminal поверх FakeRunner;
+// opened получает ID каждой открытой сессии
+func terminalServer(t *testing.T, limits sandbox.Limits) (*Handler, *sandbox.FakeRunner, string, <-chan string) {
+	runner := sandbox.NewFakeRunner()
+	h := &Handler{Sandbox: sandbox.NewManager(runner, sandbox.Spec{Image: "test:latest"}, limits)}
+	lab := &models.Lab{Title: "Docker basics", Commands: []string{"docker ps"}}
+
+	opened := make(chan string, 10)
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		h.serveTerminal(ws, 1, labFiles(lab), func(s sandbox.Session) { opened <- s.ID() })
+	}))
+	t.Cleanup(srv.Close)
+	return h, runner, "ws" + strings.TrimPrefix(srv.URL, "http"), opened
+}
+
+func dialTerminal(t *testing.T, url string) (*websocket.Conn, *bufio.Reader) {
+	t.Helper()
+	ws, err := websocket.Dial(url, "", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws, bufio.NewReader(ws)
+}
+
+func readTerminal(t *testing.T, r *bufio.Reader, want string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if strings.Contains(line, want) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("terminal closed before %q: %v", want, err)
+		}
+	}
+}
+
+// waitFor ждёт, пока cond станет истинным: сессия закрывается асинхронно
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSandboxTerminalSession(t *testing.T) {
+	h, runner, url, opened := terminalServer(t, sandbox.Limits{PerUser: 1})
+
+	ws, out := dialTerminal(t, url)
+	readTerminal(t, out, "seeded /root/lab/commands.sh")
+	readTerminal(t, out, "seeded /root/lab/README.txt")
+	ws.Write([]byte("docker ps\n"))
+	readTerminal(t, out, "fake: docker ps")
+	<-opened
+
+	// Второй терминал того же пользователя упирается в лимит
+	_, out2 := dialTerminal(t, url)
+	readTerminal(t, out2, "[sandbox: "+sandboxError(sandbox.ErrTooManySessions)+"]")
+
+	// Закрытие WebSocket останавливает контейнер и освобождает слот
+	ws.Close()
+	waitFor(t, "session cleanup", func() bool { return h.Sandbox.Active() == 0 && runner.Running() == 0 })
+
+	_, out3 := dialTerminal(t, url)
+	readTerminal(t, out3, "fake sandbox")
+}
+
+func TestSandboxTerminalCapacity(t *testing.T) {
+	_, _, url, opened := terminalServer(t, sandbox.Limits{PerUser: 5, Total: 1})
+
+	_, out := dialTerminal(t, url)
+	readTerminal(t, out, "fake sandbox")
+	<-opened
+
+	_, out2 := dialTerminal(t, url)
+	readTerminal(t, out2, "[sandbox: "+sandboxError(sandbox.ErrCapacity)+"]")
+}
+
+func TestSandboxTerminalIdleReaped(t *testing.T) {
+	h, runner, url, opened := terminalServer(t, sandbox.Limits{IdleTimeout: 50 * time.Millisecond})
+
+	_, out := dialTerminal(t, url)
+	readTerminal(t, out, "fake sandbox")
+	<-opened
+
+	// Простаивающий терминал закрывается, клиент получает конец потока
+	h.Sandbox.Start(20 * time.Millisecond)
+	waitFor(t, "idle session reaped", func() bool { return h.Sandbox.Active() == 0 && runner.Running() == 0 })
+	if _, err := io.ReadAll(out); err != nil {
+		t.Errorf("terminal after reaping: %v", err)
+	}
+}
+
+func TestSandboxTerminalDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	r := gin.New()
+	r.GET("/api/sandbox/terminal", h.SandboxTerminal)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sandbox/terminal?lab_id=1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+
+	h.Sandbox = sandbox.NewManager(sandbox.NewFakeRunner(), sandbox.Spec{}, sandbox.Limits{})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sandbox/terminal", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status without lab_id = %d, want 400", w.Code)
+	}
+}
+
+func TestLabFiles(t *testing.T) {
+	lab := &models.Lab{Title: "Kubernetes", Commands: []string{"kubectl get nodes", "kubectl get pods"}}
+	files := labFiles(lab)
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	script := string(files[0].Data)
+	if files[0].Mode != 0755 || !strings.HasPrefix(script, "#!/bin/bash\nset -e\n") || !strings.HasSuffix(script, "kubectl get nodes\nkubectl get pods\n") {
+		t.Errorf("commands.sh = %q (mode %o)", script, files[0].Mode)
+	}
+	if readme := string(files[1].Data); !strings.Contains(readme, "2. kubectl get pods") {
+		t.Errorf("README.txt = %q", readme)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"devops-manual/internal/models"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// StartScheduler раз в interval применяет плановую публикацию и снятие лаб.
+// Безопасно при нескольких репликах: переходы выполняются под advisory lock.
+func (h *Handler) StartScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for ; true; <-ticker.C {
+			published, archived, err := h.DB.RunScheduledTransitions()
+			if err != nil {
+				log.Println("ERROR scheduler:", err)
+				continue
+			}
+
+			for _, lab := range published {
+				h.auditSystem("lab.publish", lab.ID)
+				h.Monitor.SendAlert(fmt.Sprintf("📝 Новая лаба опубликована: %s", lab.Title))
+			}
+			for _, lab := range archived {
+				h.auditSystem("lab.unpublish", lab.ID)
+				h.Monitor.SendAlert(fmt.Sprintf("📦 Лаба снята с публикации: %s", lab.Title))
+			}
+		}
+	}()
+}
+
+// auditSystem пишет событие фоновой задачи (без HTTP запроса и пользователя)
+func (h *Handler) auditSystem(action string, labID int) {
+	err := h.DB.AddAuditEvent(&models.AuditEvent{
+		ActorName:  "system",
+		Action:     action,
+		TargetType: "lab",
+		TargetID:   strconv.Itoa(labID),
+	})
+	if err != nil {
+		log.Println("ERROR audit:", action, err)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"devops-manual/internal/labscript"
+	"devops-manual/internal/labvars"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLabScript - GET /api/labs/:topic/:lab/script.sh?format=sh|ps1|makefile|ansible&vars={...}
+// Команды лабы одним сценарием с подставленными переменными
+func (h *Handler) GetLabScript(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", labscript.FormatShell))
+	if !oneOf(format, labscript.Formats) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of: " + strings.Join(labscript.Formats, ", ")})
+		return
+	}
+
+	lab, err := h.DB.GetLabBySlug(c.Param("topic"), c.Param("lab"), !canEdit(h.viewer(c)))
+	if err != nil {
+		log.Println("ERROR GetLabScript:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
+		return
+	}
+
+	values, err := labvars.ParseValues(c.Query("vars"))
+	if err == nil {
+		values, err = labvars.Values(lab.Variables, values)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	script, err := labscript.Render(labvars.RenderLab(lab, values), format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, labscript.Filename(lab, format)))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(script))
+}
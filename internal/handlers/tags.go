@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetTags(c *gin.Context) {
+	tags, err := h.DB.GetTags()
+	if err != nil {
+		log.Println("ERROR GetTags:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+func (h *Handler) CreateTag(c *gin.Context) {
+	var tag models.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil || tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	if err := h.DB.CreateTag(&tag); err != nil {
+		log.Println("ERROR CreateTag:", err)
+		if errors.Is(err, database.ErrEmptySlug) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "tag.create", "tag", tag.ID, nil, tag)
+	c.JSON(http.StatusCreated, tag)
+}
+
+func (h *Handler) UpdateTag(c *gin.Context) {
+	var tag models.Tag
+	if err := c.ShouldBindJSON(&tag); err != nil || tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	tag.ID, _ = strconv.Atoi(c.Param("id"))
+
+	before, err := h.DB.GetTagByID(tag.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	if err := h.DB.UpdateTag(&tag); err != nil {
+		log.Println("ERROR UpdateTag:", err)
+		if errors.Is(err, database.ErrEmptySlug) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if database.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "tag.update", "tag", tag.ID, before, tag)
+	c.JSON(http.StatusOK, tag)
+}
+
+func (h *Handler) DeleteTag(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	before, err := h.DB.GetTagByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	if err := h.DB.DeleteTag(id); err != nil && err != sql.ErrNoRows {
+		log.Println("ERROR DeleteTag:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "tag.delete", "tag", id, before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+}
+
+// GetTagLabs - лабы с тегом из всех тем, с той же пагинацией, что и /api/labs
+func (h *Handler) GetTagLabs(c *gin.Context) {
+	if _, err := h.DB.GetTagBySlug(c.Param("tag")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	q, err := h.labQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.Tag = c.Param("tag")
+
+	labs, next, total, err := h.DB.ListLabs(q)
+	if err != nil {
+		log.Println("ERROR GetTagLabs:", err)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("Link", paginationLinks(c.Request.URL, next))
+	c.JSON(http.StatusOK, labs)
+}
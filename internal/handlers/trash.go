@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrash - список лаб в корзине
+func (h *Handler) GetTrash(c *gin.Context) {
+	labs, err := h.DB.GetDeletedLabs()
+	if err != nil {
+		log.Println("ERROR GetTrash:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, labs)
+}
+
+// RestoreLab возвращает лабу из корзины
+func (h *Handler) RestoreLab(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := h.DB.RestoreLab(id); err != nil {
+		log.Println("ERROR RestoreLab:", err)
+		switch {
+		case err == sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found in trash"})
+		case database.IsUniqueViolation(err):
+			c.JSON(http.StatusConflict, gin.H{"error": "A lab with the same slug already exists in this topic"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	lab, _ := h.DB.GetLabByID(id)
+	h.audit(c, "lab.restore", "lab", id, nil, lab)
+	c.JSON(http.StatusOK, lab)
+}
+
+// PurgeLab окончательно удаляет лабу из корзины
+func (h *Handler) PurgeLab(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	before, err := h.DB.GetDeletedLabByID(id)
+	if err != nil {
+		log.Println("ERROR PurgeLab:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found in trash"})
+		return
+	}
+
+	if err := h.DB.PurgeLab(id); err != nil {
+		log.Println("ERROR PurgeLab:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "lab.purge", "lab", id, before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Purged"})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"devops-manual/internal/labcheck"
+	"devops-manual/internal/labvars"
+	"devops-manual/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// verifyTimeout - предел на прогон всех команд лабы
+	verifyTimeout = 10 * time.Minute
+	// maxCheckOutput - сколько вывода шага хранится в истории проверок
+	maxCheckOutput = 4000
+)
+
+// checkStep сравнивает результат шага с ожиданием; без ожидания шаг должен завершиться с кодом 0
+func checkStep(r *models.StepCheckResult, e models.StepExpectation) {
+	if r.ExitCode == nil {
+		r.Reason = "команда не завершилась"
+		return
+	}
+
+	want := 0
+	if e.ExitCode != nil {
+		want = *e.ExitCode
+	}
+	if *r.ExitCode != want {
+		r.Reason = fmt.Sprintf("код выхода %d, ожидался %d", *r.ExitCode, want)
+		return
+	}
+
+	if e.Output != "" {
+		if pattern, ok := labcheck.OutputRegexp(e.Output); ok {
+			// Ожидания проверяются при сохранении, но в базе могут остаться старые
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				r.Reason = fmt.Sprintf("некорректное регулярное выражение %s: %v", e.Output, err)
+				return
+			}
+			if !re.MatchString(r.Output) {
+				r.Reason = "вывод не совпадает с " + e.Output
+				return
+			}
+		} else if !strings.Contains(r.Output, e.Output) {
+			r.Reason = fmt.Sprintf("в выводе нет %q", e.Output)
+			return
+		}
+	}
+	r.Passed = true
+}
+
+// verifyLab прогоняет команды лабы в одноразовом контейнере и сохраняет результат.
+// Ошибка запуска песочницы (нет мест, Docker недоступен) не считается провалом лабы
+// и в историю не пишется.
+func (h *Handler) verifyLab(ctx context.Context, lab *models.Lab, trigger string, userID int) (*models.LabCheck, error) {
+	// Проверяем команды со значениями переменных по умолчанию
+	lab = labvars.Defaults(lab)
+	started := time.Now()
+	results, err := h.Sandbox.RunBatch(ctx, labFiles(lab), lab.Commands, verifyTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &models.LabCheck{
+		LabID:      lab.ID,
+		Passed:     true,
+		Trigger:    trigger,
+		UserID:     userID,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	for i, res := range results {
+		step := models.StepCheckResult{Step: i, Command: lab.Commands[i], Output: res.Output}
+		if len(step.Output) > maxCheckOutput {
+			step.Output = step.Output[:maxCheckOutput] + "\n…"
+		}
+		if res.Done {
+			code := res.ExitCode
+			step.ExitCode = &code
+		}
+
+		var expected models.StepExpectation
+		if i < len(lab.Expected) {
+			expected = lab.Expected[i]
+		}
+		checkStep(&step, expected)
+		if !step.Passed && check.Passed {
+			check.Passed = false
+			check.Error = fmt.Sprintf("шаг %d: %s", i+1, step.Reason)
+		}
+		check.Steps = append(check.Steps, step)
+	}
+
+	// Оповещаем, только когда лаба сломалась, а не на каждом повторном провале
+	previous, err := h.DB.GetLabChecks(lab.ID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.DB.CreateLabCheck(check); err != nil {
+		return nil, err
+	}
+	if !check.Passed && (len(previous) == 0 || previous[0].Passed) {
+		go h.Monitor.SendAlert(fmt.Sprintf("⚠️ Лаба «%s» не прошла проверку: %s", lab.Title, check.Error))
+	}
+	return check, nil
+}
+
+// VerifyLab - POST /api/labs/:id/verify: проверить лабу сейчас
+func (h *Handler) VerifyLab(c *gin.Context) {
+	if h.Sandbox == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sandbox is disabled"})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	lab, err := h.DB.GetLabByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
+		return
+	}
+	if len(lab.Commands) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lab has no commands"})
+		return
+	}
+
+	check, err := h.verifyLab(c.Request.Context(), lab, models.CheckManual, c.GetInt("user_id"))
+	if err != nil {
+		log.Println("ERROR VerifyLab:", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": sandboxError(err)})
+		return
+	}
+
+	h.audit(c, "lab.verify", "lab", lab.ID, nil, gin.H{"check_id": check.ID, "passed": check.Passed})
+	c.JSON(http.StatusOK, check)
+}
+
+// GetLabChecks - GET /api/checks?lab_id=&limit=: история проверок лабы.
+// GET /api/labs/:id/... занят маршрутом /api/labs/:topic/:lab.
+func (h *Handler) GetLabChecks(c *gin.Context) {
+	labID, err := strconv.Atoi(c.Query("lab_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lab_id is required"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	checks, err := h.DB.GetLabChecks(labID, limit)
+	if err != nil {
+		log.Println("ERROR GetLabChecks:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, checks)
+}
+
+// StartVerifier раз в interval проверяет, не пора ли ночного прогона: после hour
+// часов по серверному времени все опубликованные лабы проверяются один раз за сутки.
+// Лабы проверяются по очереди, чтобы не занимать все слоты песочницы.
+func (h *Handler) StartVerifier(hour int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for ; true; <-ticker.C {
+			now := time.Now()
+			if now.Hour() < hour {
+				continue
+			}
+			claimed, err := h.DB.ClaimNightlyChecks(now)
+			if err != nil {
+				log.Println("ERROR verifier:", err)
+				continue
+			}
+			if claimed {
+				h.runNightlyChecks()
+			}
+		}
+	}()
+}
+
+func (h *Handler) runNightlyChecks() {
+	labs, err := h.DB.GetVerifiableLabs()
+	if err != nil {
+		log.Println("ERROR verifier:", err)
+		return
+	}
+
+	failed := 0
+	for i := range labs {
+		check, err := h.verifyLab(context.Background(), &labs[i], models.CheckNightly, 0)
+		if err != nil {
+			log.Printf("ERROR verifier: lab %d: %v", labs[i].ID, err)
+			continue
+		}
+		if !check.Passed {
+			failed++
+		}
+		h.auditSystem("lab.verify", labs[i].ID)
+	}
+	log.Printf("Verifier: checked %d labs, %d failed", len(labs), failed)
+}
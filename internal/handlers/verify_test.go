@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"devops-manual/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestCheckStep(t *testing.T) {
+	code := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		exitCode *int
+		output   string
+		expected models.StepExpectation
+		reason   string // "" - шаг пройден
+	}{
+		{"success by default", code(0), "", models.StepExpectation{}, ""},
+		{"not finished", nil, "", models.StepExpectation{}, "не завершилась"},
+		{"unexpected exit code", code(1), "", models.StepExpectation{}, "код выхода 1, ожидался 0"},
+		{"expected failure", code(2), "", models.StepExpectation{ExitCode: code(2)}, ""},
+		{"substring", code(0), "Server Version: 24.0", models.StepExpectation{Output: "Server Version"}, ""},
+		{"missing substring", code(0), "error", models.StepExpectation{Output: "Running"}, `в выводе нет "Running"`},
+		{"regexp", code(0), "pod/web-1 Running", models.StepExpectation{Output: `/web-\d+ Running/`}, ""},
+		{"regexp mismatch", code(0), "pod/web-1 Pending", models.StepExpectation{Output: `/Running/`}, "не совпадает"},
+		{"invalid regexp", code(0), "anything", models.StepExpectation{Output: `/([a-z/`}, "некорректное регулярное выражение"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := models.StepCheckResult{ExitCode: tt.exitCode, Output: tt.output}
+			checkStep(&r, tt.expected)
+
+			if tt.reason == "" {
+				if !r.Passed || r.Reason != "" {
+					t.Errorf("step failed: %q", r.Reason)
+				}
+				return
+			}
+			if r.Passed || !strings.Contains(r.Reason, tt.reason) {
+				t.Errorf("Passed = %v, Reason = %q, want failure with %q", r.Passed, r.Reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"database/sql"
+	"devops-manual/internal/database"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubmitLab - редактор отправляет черновик на ревью
+func (h *Handler) SubmitLab(c *gin.Context) {
+	h.transitionLab(c, "submit", models.LabStatusInReview, models.LabStatusDraft)
+}
+
+// ApproveLab - ревьюер публикует лабу. Если publish_at в будущем,
+// лаба ждёт его в статусе scheduled и публикуется планировщиком.
+// У опубликованной или запланированной лабы принимает ожидающую правку.
+func (h *Handler) ApproveLab(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	to := models.LabStatusPublished
+	if lab, err := h.DB.GetLabByID(id); err == nil {
+		if isLive(lab) {
+			h.reviewRevision(c, lab, "approve")
+			return
+		}
+		if lab.PublishAt != nil && lab.PublishAt.After(time.Now()) {
+			to = models.LabStatusScheduled
+		}
+	}
+
+	lab := h.transitionLab(c, "approve", to, models.LabStatusInReview)
+	if lab != nil && lab.Status == models.LabStatusPublished {
+		h.Monitor.SendAlert(fmt.Sprintf("📝 Новая лаба опубликована: %s", lab.Title))
+	}
+}
+
+// RequestChanges - ревьюер возвращает лабу в черновики с комментарием.
+// Ожидающую правку опубликованной лабы отклоняет, лаба остаётся опубликованной.
+func (h *Handler) RequestChanges(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if lab, err := h.DB.GetLabByID(id); err == nil && isLive(lab) {
+		h.reviewRevision(c, lab, "request_changes")
+		return
+	}
+	h.transitionLab(c, "request_changes", models.LabStatusDraft, models.LabStatusInReview)
+}
+
+// ArchiveLab снимает лабу с публикации; ожидающая правка удаляется
+func (h *Handler) ArchiveLab(c *gin.Context) {
+	h.transitionLab(c, "archive", models.LabStatusArchived, models.LabStatusPublished, models.LabStatusScheduled)
+}
+
+// UnarchiveLab возвращает архивную лабу в черновики: снова опубликовать её
+// можно только через ревью
+func (h *Handler) UnarchiveLab(c *gin.Context) {
+	h.transitionLab(c, "unarchive", models.LabStatusDraft, models.LabStatusArchived)
+}
+
+// isLive - лаба видна читателям или ждёт публикации по расписанию;
+// правки редакторов таких лаб проходят ревью отдельно (см. submitRevision)
+func isLive(lab *models.Lab) bool {
+	return lab.Status == models.LabStatusPublished || lab.Status == models.LabStatusScheduled
+}
+
+// mergeRevision накладывает правку из запроса на текущую лабу так же, как
+// UpdateLab: списки, которых нет в запросе, остаются прежними. Расписание
+// уже сведено вызывающим. Результат - полная лаба для SetLabRevision.
+func mergeRevision(before, lab *models.Lab, publishAt, unpublishAt *time.Time) *models.Lab {
+	rev := *lab
+	rev.ID = before.ID
+	if rev.Expected == nil {
+		rev.Expected = before.Expected
+	}
+	if rev.Variables == nil {
+		rev.Variables = before.Variables
+	}
+	if rev.DestructiveSteps == nil {
+		rev.DestructiveSteps = before.DestructiveSteps
+	}
+	if rev.Tags == nil {
+		rev.Tags = before.Tags
+	}
+	if rev.Requires == nil {
+		rev.Requires = before.Requires
+	}
+	rev.PublishAt, rev.UnpublishAt = publishAt, unpublishAt
+	rev.ClearSchedule = true
+	rev.Status = ""
+	return &rev
+}
+
+// submitRevision сохраняет правку опубликованной лабы до ревью; новая правка
+// заменяет ожидающую. Отвечает 202: лаба для читателей не изменилась.
+func (h *Handler) submitRevision(c *gin.Context, before, rev *models.Lab) {
+	if err := h.validateRequires(rev.Requires); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.DB.SetLabRevision(before.ID, rev); err != nil {
+		log.Println("ERROR submitRevision:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	review := &models.LabReview{LabID: before.ID, UserID: c.GetInt("user_id"), Action: "submit", Comment: "Правка опубликованной лабы"}
+	if err := h.DB.AddLabReview(review); err != nil {
+		log.Println("ERROR submitRevision review:", err)
+	}
+	h.audit(c, "lab.submit_revision", "lab", before.ID, before, rev)
+	c.JSON(http.StatusAccepted, rev)
+}
+
+// reviewRevision принимает (approve) или отклоняет (request_changes) ожидающую
+// правку опубликованной лабы; статус лабы не меняется
+func (h *Handler) reviewRevision(c *gin.Context, lab *models.Lab, action string) {
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	// Тело необязательно
+	c.ShouldBindJSON(&req)
+
+	if action == "request_changes" && req.Comment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment is required"})
+		return
+	}
+
+	rev, err := h.DB.GetLabRevision(lab.ID)
+	if err == nil && rev == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot %s lab in status %s: no pending revision", action, lab.Status)})
+		return
+	}
+	if err == nil {
+		if action == "approve" {
+			_, err = h.DB.ApplyLabRevision(lab.ID)
+		} else {
+			err = h.DB.SetLabRevision(lab.ID, nil)
+		}
+	}
+	if err != nil {
+		log.Println("ERROR reviewRevision:", err)
+		if errors.Is(err, database.ErrPrerequisiteCycle) || errors.Is(err, database.ErrUnknownLab) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	review := &models.LabReview{LabID: lab.ID, UserID: c.GetInt("user_id"), Action: action, Comment: req.Comment}
+	if err := h.DB.AddLabReview(review); err != nil {
+		log.Println("ERROR reviewRevision review:", err)
+	}
+
+	after, _ := h.DB.GetLabByID(lab.ID)
+	if action == "approve" {
+		h.audit(c, "lab.approve_revision", "lab", lab.ID, lab, after)
+	} else {
+		h.audit(c, "lab.reject_revision", "lab", lab.ID, rev, nil)
+	}
+	c.JSON(http.StatusOK, after)
+}
+
+// GetLabReviews - история ревью лабы: GET /api/reviews?lab_id=
+// (GET /api/labs/:id/... занят маршрутом /api/labs/:topic/:lab)
+func (h *Handler) GetLabReviews(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("lab_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lab_id is required"})
+		return
+	}
+	reviews, err := h.DB.GetLabReviews(id)
+	if err != nil {
+		log.Println("ERROR GetLabReviews:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// transitionLab меняет статус лабы, пишет историю ревью и аудит.
+// Возвращает лабу после перехода или nil, если ответ с ошибкой уже отправлен.
+func (h *Handler) transitionLab(c *gin.Context, action, to string, from ...string) *models.Lab {
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	// Тело необязательно
+	c.ShouldBindJSON(&req)
+
+	if action == "request_changes" && req.Comment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment is required"})
+		return nil
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	before, err := h.DB.GetLabByID(id)
+	if err != nil {
+		log.Println("ERROR transitionLab:", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lab not found"})
+		return nil
+	}
+
+	if err := h.DB.SetLabStatus(id, to, from...); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot %s lab in status %s", action, before.Status)})
+			return nil
+		}
+		log.Println("ERROR transitionLab:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+
+	review := &models.LabReview{LabID: id, UserID: c.GetInt("user_id"), Action: action, Comment: req.Comment}
+	if err := h.DB.AddLabReview(review); err != nil {
+		log.Println("ERROR transitionLab review:", err)
+	}
+
+	after, _ := h.DB.GetLabByID(id)
+	h.audit(c, "lab."+action, "lab", id, before, after)
+	c.JSON(http.StatusOK, after)
+	return after
+}
+
+// viewer возвращает пользователя по сессии для публичных страниц (nil - аноним)
+func (h *Handler) viewer(c *gin.Context) *models.User {
+	token := sessionToken(c)
+	if token == "" {
+		return nil
+	}
+	session := h.DB.GetSession(token)
+	if session == nil {
+		return nil
+	}
+	user, err := h.DB.GetUserByID(session.UserID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// canEdit - редакторы и выше видят черновики и могут править лабы
+func canEdit(u *models.User) bool {
+	return u != nil && models.RoleRank(u.Role) >= models.RoleRank(models.RoleEditor)
+}
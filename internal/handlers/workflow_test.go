@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"devops-manual/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeRevision(t *testing.T) {
+	publishAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := &models.Lab{
+		ID:               7,
+		Title:            "Docker",
+		Status:           models.LabStatusPublished,
+		Tags:             []string{"docker"},
+		Requires:         []string{"linux/basics"},
+		Variables:        []models.LabVariable{{Name: "image", Default: "nginx"}},
+		DestructiveSteps: []int{1},
+		PublishAt:        &publishAt,
+	}
+	// Правка без tags, requires и variables в запросе; destructive_steps очищены
+	lab := &models.Lab{Title: "Docker, исправлена опечатка", Commands: []string{"docker ps", "docker rm -f web"}, DestructiveSteps: []int{}, Status: "draft"}
+
+	rev := mergeRevision(before, lab, &publishAt, nil)
+	if rev.ID != 7 || rev.Title != lab.Title || !reflect.DeepEqual(rev.Commands, lab.Commands) {
+		t.Errorf("request fields not taken: %+v", rev)
+	}
+	if !reflect.DeepEqual(rev.Tags, before.Tags) || !reflect.DeepEqual(rev.Requires, before.Requires) || !reflect.DeepEqual(rev.Variables, before.Variables) {
+		t.Errorf("omitted fields not kept: %+v", rev)
+	}
+	if len(rev.DestructiveSteps) != 0 {
+		t.Errorf("DestructiveSteps = %v, want cleared", rev.DestructiveSteps)
+	}
+	if rev.Status != "" || !rev.ClearSchedule || rev.PublishAt != &publishAt || rev.UnpublishAt != nil {
+		t.Errorf("status or schedule: %+v", rev)
+	}
+	if lab.Status != "draft" || lab.ClearSchedule {
+		t.Error("mergeRevision modified the request")
+	}
+}
+
+func TestIsLive(t *testing.T) {
+	for status, want := range map[string]bool{
+		models.LabStatusDraft:     false,
+		models.LabStatusInReview:  false,
+		models.LabStatusScheduled: true,
+		models.LabStatusPublished: true,
+		models.LabStatusArchived:  false,
+	} {
+		if got := isLive(&models.Lab{Status: status}); got != want {
+			t.Errorf("isLive(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
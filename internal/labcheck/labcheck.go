@@ -0,0 +1,51 @@
+// Package labcheck проверяет содержимое лабы перед сохранением: ожидания шагов,
+// необратимые шаги и переменные. Одни и те же правила действуют для API и для
+// восстановления из архива.
+package labcheck
+
+import (
+	"devops-manual/internal/labvars"
+	"devops-manual/internal/models"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validate выполняет все проверки и возвращает все найденные ошибки сразу
+func Validate(lab *models.Lab) error {
+	return errors.Join(Expectations(lab), DestructiveSteps(lab), labvars.Validate(lab))
+}
+
+// Expectations проверяет ожидания шагов: не больше, чем команд, регулярки компилируются
+func Expectations(lab *models.Lab) error {
+	if len(lab.Expected) > len(lab.Commands) {
+		return fmt.Errorf("expected has %d steps, lab has only %d commands", len(lab.Expected), len(lab.Commands))
+	}
+	for i, e := range lab.Expected {
+		if pattern, ok := OutputRegexp(e.Output); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("expected[%d].output: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// OutputRegexp выделяет регулярное выражение из ожидания вида /.../
+func OutputRegexp(s string) (string, bool) {
+	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+// DestructiveSteps проверяет, что необратимые шаги ссылаются на существующие команды
+func DestructiveSteps(lab *models.Lab) error {
+	for _, step := range lab.DestructiveSteps {
+		if step < 0 || step >= len(lab.Commands) {
+			return fmt.Errorf("destructive_steps: step %d out of range (lab has %d commands)", step, len(lab.Commands))
+		}
+	}
+	return nil
+}
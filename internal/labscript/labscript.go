@@ -0,0 +1,204 @@
+// Package labscript превращает команды лабы в готовый к запуску сценарий:
+// bash-скрипт, PowerShell, Makefile или Ansible playbook. Переменные
+// должны быть подставлены заранее (см. labvars).
+package labscript
+
+import (
+	"devops-manual/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Форматы экспорта
+const (
+	FormatShell      = "sh"
+	FormatPowerShell = "ps1"
+	FormatMakefile   = "makefile"
+	FormatAnsible    = "ansible"
+)
+
+// Formats - поддерживаемые форматы, первый - по умолчанию
+var Formats = []string{FormatShell, FormatPowerShell, FormatMakefile, FormatAnsible}
+
+// ErrUnknownFormat - запрошен неподдерживаемый формат
+var ErrUnknownFormat = errors.New("unknown script format")
+
+// Render возвращает сценарий лабы в формате format
+func Render(lab *models.Lab, format string) (string, error) {
+	destructive := map[int]bool{}
+	for _, step := range lab.DestructiveSteps {
+		destructive[step] = true
+	}
+
+	switch format {
+	case FormatShell:
+		return shell(lab, destructive), nil
+	case FormatPowerShell:
+		return powerShell(lab, destructive), nil
+	case FormatMakefile:
+		return makefile(lab, destructive), nil
+	case FormatAnsible:
+		return ansible(lab, destructive), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// Filename - имя файла для Content-Disposition
+func Filename(lab *models.Lab, format string) string {
+	switch format {
+	case FormatPowerShell:
+		return lab.Slug + ".ps1"
+	case FormatMakefile:
+		return "Makefile"
+	case FormatAnsible:
+		return lab.Slug + ".yml"
+	}
+	return lab.Slug + ".sh"
+}
+
+// singleQuote экранирует строку для bash в одинарных кавычках
+func singleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// banner - строка "[2/5] команда" для вывода перед шагом; у многострочной
+// команды показывается только первая строка
+func banner(i, total int, cmd string) string {
+	if first, _, ok := strings.Cut(cmd, "\n"); ok {
+		cmd = strings.TrimRight(first, "\r") + " ..."
+	}
+	return fmt.Sprintf("[%d/%d] %s", i+1, total, cmd)
+}
+
+// comment - строка для комментария "# ...": переводы строк заменяются пробелами
+func comment(s string) string {
+	return strings.Join(strings.Fields(strings.NewReplacer("\r", " ", "\n", " ").Replace(s)), " ")
+}
+
+func shell(lab *models.Lab, destructive map[int]bool) string {
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env bash\n")
+	fmt.Fprintf(&b, "# %s\n", comment(lab.Title))
+	b.WriteString("set -euo pipefail\n")
+
+	if len(destructive) > 0 {
+		b.WriteString(`
+# confirm спрашивает подтверждение перед необратимым шагом; отказ прерывает скрипт
+confirm() {
+    local answer
+    read -r -p "$1 [y/N] " answer </dev/tty
+    case "$answer" in
+        [yY]*) ;;
+        *) echo "Прервано"; exit 1 ;;
+    esac
+}
+`)
+	}
+
+	for i, cmd := range lab.Commands {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "echo %s\n", singleQuote("==> "+banner(i, len(lab.Commands), cmd)))
+		if destructive[i] {
+			fmt.Fprintf(&b, "confirm %s\n", singleQuote("Шаг необратим. Выполнить?"))
+		}
+		b.WriteString(cmd + "\n")
+	}
+	return b.String()
+}
+
+// psQuote экранирует строку для PowerShell в одинарных кавычках
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func powerShell(lab *models.Lab, destructive map[int]bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", comment(lab.Title))
+	b.WriteString("$ErrorActionPreference = 'Stop'\n")
+
+	for i, cmd := range lab.Commands {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "Write-Host %s\n", psQuote("==> "+banner(i, len(lab.Commands), cmd)))
+		if destructive[i] {
+			b.WriteString("$answer = Read-Host 'Шаг необратим. Выполнить? [y/N]'\n")
+			b.WriteString("if ($answer -notmatch '^[yY]') { Write-Host 'Прервано'; exit 1 }\n")
+		}
+		// Внешние программы не бросают исключений, код выхода проверяем явно.
+		// Командлеты $LASTEXITCODE не меняют, поэтому перед шагом он сбрасывается:
+		// иначе после cd или New-Item проверка увидела бы $null
+		b.WriteString("$global:LASTEXITCODE = 0\n")
+		b.WriteString(cmd + "\n")
+		b.WriteString("if ($LASTEXITCODE -ne 0) { exit $LASTEXITCODE }\n")
+	}
+	return b.String()
+}
+
+func makefile(lab *models.Lab, destructive map[int]bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", comment(lab.Title))
+	b.WriteString("SHELL := /bin/bash\n")
+	b.WriteString(".SHELLFLAGS := -euo pipefail -c\n")
+	// Рецепт шага - один вызов оболочки: многострочные команды (heredoc,
+	// продолжения строк) не разрываются по строкам
+	b.WriteString(".ONESHELL:\n\n")
+
+	targets := make([]string, len(lab.Commands))
+	for i := range lab.Commands {
+		targets[i] = fmt.Sprintf("step-%d", i+1)
+	}
+	fmt.Fprintf(&b, ".PHONY: all %s\n\n", strings.Join(targets, " "))
+	fmt.Fprintf(&b, "all: %s\n", strings.Join(targets, " "))
+
+	for i, cmd := range lab.Commands {
+		b.WriteString("\n")
+		// Шаги идут по порядку: make step-3 выполнит и предыдущие
+		if i > 0 {
+			fmt.Fprintf(&b, "%s: %s\n", targets[i], targets[i-1])
+		} else {
+			fmt.Fprintf(&b, "%s:\n", targets[i])
+		}
+		fmt.Fprintf(&b, "\t@echo %s\n", makeEscape(singleQuote("==> "+banner(i, len(lab.Commands), cmd))))
+		if destructive[i] {
+			b.WriteString("\t@read -r -p 'Шаг необратим. Выполнить? [y/N] ' answer </dev/tty; case \"$$answer\" in [yY]*) ;; *) echo 'Прервано'; exit 1 ;; esac\n")
+		}
+		for _, line := range strings.Split(makeEscape(cmd), "\n") {
+			fmt.Fprintf(&b, "\t%s\n", strings.TrimRight(line, "\r"))
+		}
+	}
+	return b.String()
+}
+
+// makeEscape экранирует $ в рецепте, чтобы make не подставлял свои переменные
+func makeEscape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+// yamlString - строка в кавычках; JSON - подмножество YAML
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func ansible(lab *models.Lab, destructive map[int]bool) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "- name: %s\n", yamlString(lab.Title))
+	b.WriteString("  hosts: all\n")
+	b.WriteString("  gather_facts: false\n")
+	b.WriteString("  tasks:\n")
+
+	for i, cmd := range lab.Commands {
+		if destructive[i] {
+			fmt.Fprintf(&b, "    - name: %s\n", yamlString(fmt.Sprintf("Подтверждение шага %d", i+1)))
+			b.WriteString("      ansible.builtin.pause:\n")
+			fmt.Fprintf(&b, "        prompt: %s\n", yamlString("Шаг необратим: "+cmd+". Enter - выполнить, Ctrl+C - прервать"))
+		}
+		fmt.Fprintf(&b, "    - name: %s\n", yamlString(banner(i, len(lab.Commands), cmd)))
+		fmt.Fprintf(&b, "      ansible.builtin.shell: %s\n", yamlString("set -euo pipefail\n"+cmd))
+		b.WriteString("      args:\n")
+		b.WriteString("        executable: /bin/bash\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package labscript
+
+import (
+	"devops-manual/internal/models"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testLab() *models.Lab {
+	return &models.Lab{
+		Title: "Docker\nвведение",
+		Slug:  "docker-basics",
+		Commands: []string{
+			"mkdir -p /tmp/demo",
+			"cat <<'EOF' > /tmp/demo/app.conf\nport=$PORT\nEOF",
+			"docker rm -f web",
+		},
+		DestructiveSteps: []int{2},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    []string
+		notWant []string
+	}{
+		{FormatShell, []string{
+			"#!/usr/bin/env bash\n# Docker введение\nset -euo pipefail\n",
+			"echo '==> [2/3] cat <<'\\''EOF'\\'' > /tmp/demo/app.conf ...'\n",
+			"cat <<'EOF' > /tmp/demo/app.conf\nport=$PORT\nEOF\n",
+			"confirm 'Шаг необратим. Выполнить?'\ndocker rm -f web\n",
+		}, nil},
+		{FormatPowerShell, []string{
+			"# Docker введение\n$ErrorActionPreference = 'Stop'\n",
+			// Сброс перед каждым шагом: после командлета $LASTEXITCODE остался бы $null
+			"$global:LASTEXITCODE = 0\nmkdir -p /tmp/demo\nif ($LASTEXITCODE -ne 0) { exit $LASTEXITCODE }\n",
+			"Read-Host 'Шаг необратим. Выполнить? [y/N]'\n",
+		}, nil},
+		{FormatMakefile, []string{
+			"# Docker введение\nSHELL := /bin/bash\n",
+			".ONESHELL:\n",
+			"all: step-1 step-2 step-3\n",
+			"step-2: step-1\n\t@echo '==> [2/3] cat <<'\\''EOF'\\'' > /tmp/demo/app.conf ...'\n" +
+				"\tcat <<'EOF' > /tmp/demo/app.conf\n\tport=$$PORT\n\tEOF\n",
+			"\t@read -r -p 'Шаг необратим. Выполнить? [y/N] ' answer </dev/tty;",
+		}, []string{"\nport="}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			script, err := Render(testLab(), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(script, w) {
+					t.Errorf("script does not contain %q:\n%s", w, script)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(script, w) {
+					t.Errorf("script contains %q:\n%s", w, script)
+				}
+			}
+			if strings.Count(script, "Шаг необратим") != 1 {
+				t.Errorf("want exactly one confirmation:\n%s", script)
+			}
+		})
+	}
+}
+
+func TestMakefileRecipeLines(t *testing.T) {
+	script, _ := Render(testLab(), FormatMakefile)
+	// Внутри рецепта каждая строка начинается с табуляции, иначе make не разберёт файл
+	inRecipe := false
+	for _, line := range strings.Split(script, "\n") {
+		switch {
+		case strings.HasPrefix(line, "step-"):
+			inRecipe = true
+		case line == "":
+			inRecipe = false
+		case inRecipe && !strings.HasPrefix(line, "\t"):
+			t.Errorf("recipe line without tab: %q", line)
+		}
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if _, err := Render(testLab(), "bat"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v, want ErrUnknownFormat", err)
+	}
+}
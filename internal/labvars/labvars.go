@@ -0,0 +1,150 @@
+// Package labvars подставляет переменные лаб в текст и команды.
+// Плейсхолдер записывается как {{ .namespace }}; все используемые переменные
+// должны быть объявлены в лабе со значением по умолчанию.
+package labvars
+
+import (
+	"devops-manual/internal/markdown"
+	"devops-manual/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	placeholderRe = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	nameRe        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// ErrUnknownVariable - в значениях передана переменная, которой нет в лабе
+var ErrUnknownVariable = errors.New("unknown variable")
+
+// Placeholders возвращает имена переменных, используемых в s, без повторов
+func Placeholders(s string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(s, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// Validate проверяет объявления переменных и то, что каждый плейсхолдер
+// в Content и Commands объявлен. Возвращает все найденные ошибки сразу.
+func Validate(lab *models.Lab) error {
+	var errs []error
+	declared := map[string]bool{}
+	for i, v := range lab.Variables {
+		switch {
+		case !nameRe.MatchString(v.Name):
+			errs = append(errs, fmt.Errorf("variables[%d]: invalid name %q", i, v.Name))
+		case declared[v.Name]:
+			errs = append(errs, fmt.Errorf("variables[%d]: duplicate name %q", i, v.Name))
+		case strings.ContainsAny(v.Default, "\r\n"):
+			errs = append(errs, fmt.Errorf("variables[%d]: default must be a single line", i))
+		}
+		declared[v.Name] = true
+	}
+
+	texts := append([]string{lab.Content}, lab.Commands...)
+	var undeclared []string
+	seen := map[string]bool{}
+	for _, t := range texts {
+		for _, name := range Placeholders(t) {
+			if !declared[name] && !seen[name] {
+				seen[name] = true
+				undeclared = append(undeclared, name)
+			}
+		}
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		errs = append(errs, fmt.Errorf("undeclared variables: %s", strings.Join(undeclared, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// Values собирает значения переменных: объявленные значения по умолчанию,
+// переопределённые overrides. Пустое значение в overrides оставляет значение по умолчанию.
+func Values(vars []models.LabVariable, overrides map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		values[v.Name] = v.Default
+	}
+	for name, value := range overrides {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownVariable, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("variable %s must be a single line", name)
+		}
+		if value != "" {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// ParseValues разбирает параметр ?vars= - JSON объект {"namespace": "prod"}
+func ParseValues(raw string) (map[string]string, error) {
+	values := map[string]string{}
+	if raw == "" {
+		return values, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, fmt.Errorf(`vars must be a JSON object like {"namespace": "prod"}`)
+	}
+	return values, nil
+}
+
+// Render подставляет значения в s; необъявленные плейсхолдеры остаются как есть
+func Render(s string, values map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// RenderHTML подставляет значения в текст лабы, уже отрендеренный из Markdown.
+// Значения экранируются и остаются текстом: ?vars= из чужой ссылки не добавит
+// в лабу ссылок или разметки. Так же подставляет applyVars на странице лабы.
+func RenderHTML(s string, values map[string]string) string {
+	escaped := make(map[string]string, len(values))
+	for name, v := range values {
+		escaped[name] = html.EscapeString(v)
+	}
+	return Render(s, escaped)
+}
+
+// RenderContent возвращает Content лабы в HTML: Markdown рендерится по исходнику
+// с плейсхолдерами, затем подставляются экранированные значения
+func RenderContent(lab *models.Lab, values map[string]string) string {
+	return RenderHTML(markdown.Render(lab.Content), values)
+}
+
+// RenderLab возвращает копию лабы с подставленными в Content и Commands значениями
+func RenderLab(lab *models.Lab, values map[string]string) *models.Lab {
+	out := *lab
+	out.Content = Render(lab.Content, values)
+	out.Commands = make([]string, len(lab.Commands))
+	for i, cmd := range lab.Commands {
+		out.Commands[i] = Render(cmd, values)
+	}
+	return &out
+}
+
+// Defaults - лаба с подставленными значениями по умолчанию
+func Defaults(lab *models.Lab) *models.Lab {
+	values, _ := Values(lab.Variables, nil)
+	return RenderLab(lab, values)
+}
@@ -0,0 +1,68 @@
+package labvars
+
+import (
+	"devops-manual/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestRenderContent(t *testing.T) {
+	lab := &models.Lab{
+		Content:   "Подключитесь к **{{ .host }}**\n\n`ssh {{ .host }}`",
+		Variables: []models.LabVariable{{Name: "host", Default: "example.com"}},
+	}
+
+	tests := []struct {
+		name    string
+		host    string
+		want    []string
+		notWant []string
+	}{
+		{"default", "", []string{"<strong>example.com</strong>", "<code>ssh example.com</code>"}, nil},
+		{"markdown link", "[click](https://evil.example)", []string{"[click](https://evil.example)"}, []string{"<a "}},
+		{"html", `<img src=x onerror="alert(1)">`, []string{"&lt;img src=x onerror=&#34;alert(1)&#34;&gt;"}, []string{"<img"}},
+		{"bold", "**prod**", []string{"<strong>**prod**</strong>"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := Values(lab.Variables, map[string]string{"host": tt.host})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := RenderContent(lab, values)
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("output does not contain %q:\n%s", w, out)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(out, w) {
+					t.Errorf("output contains %q:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderLab(t *testing.T) {
+	lab := &models.Lab{
+		Content:   "Namespace {{ .ns }}, {{ .unknown }}",
+		Commands:  []string{"kubectl -n {{.ns}} get pods"},
+		Variables: []models.LabVariable{{Name: "ns", Default: "myapp"}},
+	}
+	values, err := Values(lab.Variables, map[string]string{"ns": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := RenderLab(lab, values)
+	if out.Content != "Namespace prod, {{ .unknown }}" || out.Commands[0] != "kubectl -n prod get pods" {
+		t.Errorf("RenderLab = %q, %q", out.Content, out.Commands)
+	}
+	if lab.Commands[0] != "kubectl -n {{.ns}} get pods" {
+		t.Error("RenderLab modified the source lab")
+	}
+
+	if _, err := Values(lab.Variables, map[string]string{"host": "x"}); err == nil {
+		t.Error("unknown variable accepted")
+	}
+}
@@ -0,0 +1,94 @@
+// Package markdown - безопасный рендер небольшого подмножества Markdown
+// для пользовательского текста (комментарии, текст лаб): абзацы, списки, блоки
+// кода, `код`, **жирный**, *курсив*, ссылки http(s) и картинки. Исходный HTML
+// всегда экранируется. Картинки и относительные ссылки допускаются только на
+// вложения лаб (/attachments/...), чтобы текст не подгружал сторонние ресурсы.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	imageRe  = regexp.MustCompile(`!\[([^\]]*)\]\((/attachments/[^)\s]+)\)`)
+	linkRe   = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+|/attachments/[^)\s]+)\)`)
+	boldRe   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicRe = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// Render преобразует Markdown в HTML
+func Render(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var out strings.Builder
+	var para, items []string
+
+	flushPara := func() {
+		if len(para) > 0 {
+			out.WriteString("<p>" + strings.Join(para, "<br>") + "</p>")
+			para = nil
+		}
+	}
+	flushList := func() {
+		if len(items) > 0 {
+			out.WriteString("<ul>")
+			for _, item := range items {
+				out.WriteString("<li>" + item + "</li>")
+			}
+			out.WriteString("</ul>")
+			items = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushPara()
+			flushList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>")
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			flushPara()
+			items = append(items, inline(trimmed[2:]))
+		case trimmed == "":
+			flushPara()
+			flushList()
+		default:
+			flushList()
+			para = append(para, inline(trimmed))
+		}
+	}
+	flushPara()
+	flushList()
+	return out.String()
+}
+
+// inline обрабатывает строку внутри блока: `код` не форматируется
+func inline(s string) string {
+	parts := strings.Split(s, "`")
+	for i, part := range parts {
+		part = html.EscapeString(part)
+		// Нечётные части - между обратными кавычками; непарная кавычка остаётся текстом
+		if i%2 == 1 && i < len(parts)-1 {
+			parts[i] = "<code>" + part + "</code>"
+			continue
+		}
+		part = imageRe.ReplaceAllString(part, `<img src="$2" alt="$1" loading="lazy">`)
+		part = linkRe.ReplaceAllString(part, `<a href="$2" rel="nofollow noopener" target="_blank">$1</a>`)
+		part = boldRe.ReplaceAllString(part, "<strong>$1</strong>")
+		part = italicRe.ReplaceAllString(part, "<em>$1</em>")
+		if i%2 == 1 {
+			part = "`" + part
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "")
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Topic struct {
 	ID          int       `json:"id"`
@@ -11,23 +14,308 @@ type Topic struct {
 }
 
 type Lab struct {
+	ID         int      `json:"id"`
+	TopicID    int      `json:"topic_id"`
+	Topic      *Topic   `json:"topic,omitempty"`
+	Title      string   `json:"title"`
+	Slug       string   `json:"slug"`
+	Content    string   `json:"content"`
+	Commands   []string `json:"commands"`
+	Difficulty string   `json:"difficulty"`
+	Status     string   `json:"status"`
+	// Плановая публикация и снятие с публикации
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+	AuthorID    int        `json:"author_id,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	Tags        []string   `json:"tags"`   // slug'и тегов
+	Rating      float64    `json:"rating"` // средняя оценка 1-5, 0 - нет оценок
+	RatingCount int        `json:"rating_count"`
+	// Пререквизиты в виде "topic/lab", например "docker/basics"
+	Requires  []string   `json:"requires"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Ожидаемый результат команд по индексу Commands, проверяется верификатором
+	Expected []StepExpectation `json:"expected,omitempty"`
+	// Outdated - последняя автоматическая проверка лабы провалилась
+	Outdated bool `json:"outdated"`
+	// Переменные для плейсхолдеров {{ .name }} в Content и Commands
+	Variables []LabVariable `json:"variables,omitempty"`
+	// Номера (с 0) необратимых шагов: перед ними скрипт спрашивает подтверждение
+	DestructiveSteps []int `json:"destructive_steps,omitempty"`
+	// ClearSchedule - только в запросе на изменение: записать publish_at и
+	// unpublish_at как есть, null снимает дату. Без флага отсутствующие даты не меняются.
+	ClearSchedule bool `json:"clear_schedule,omitempty"`
+}
+
+// LabVariable - объявленная переменная лабы, например namespace со значением по умолчанию myapp
+type LabVariable struct {
+	Name        string `json:"name"`
+	Default     string `json:"default"`
+	Description string `json:"description,omitempty"`
+}
+
+// StepExpectation - ожидаемый результат шага лабы. ExitCode nil - код не
+// проверяется; Output - подстрока вывода или регулярное выражение вида /.../
+type StepExpectation struct {
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+// LabCheck - запуск команд лабы в одноразовом контейнере
+type LabCheck struct {
+	ID       int               `json:"id"`
+	LabID    int               `json:"lab_id"`
+	Passed   bool              `json:"passed"`
+	Trigger  string            `json:"trigger"`
+	UserID   int               `json:"user_id,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Steps    []StepCheckResult `json:"steps"`
+	// Длительность в миллисекундах
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// StepCheckResult - результат проверки одного шага
+type StepCheckResult struct {
+	Step     int    `json:"step"`
+	Command  string `json:"command"`
+	ExitCode *int   `json:"exit_code"` // nil - команда не завершилась
+	Output   string `json:"output"`
+	Passed   bool   `json:"passed"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Источники запуска проверки
+const (
+	CheckManual  = "manual"
+	CheckNightly = "nightly"
+)
+
+// Attachment - файл лабы (диаграмма, пример конфига). Содержимое хранится в
+// BlobStore под ключом BlobKey (SHA-256), одинаковые файлы - один блоб.
+type Attachment struct {
 	ID          int       `json:"id"`
-	TopicID     int       `json:"topic_id"`
-	Topic       *Topic    `json:"topic,omitempty"`
-	Title       string    `json:"title"`
-	Slug        string    `json:"slug"`
-	Content     string    `json:"content"`
-	Commands    []string  `json:"commands"`
-	Difficulty  string    `json:"difficulty"`
+	LabID       int       `json:"lab_id"`
+	BlobKey     string    `json:"blob_key"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UserID      int       `json:"user_id,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	// URL для ссылки из Markdown: /attachments/<id>/<filename>
+	URL string `json:"url"`
+}
+
+// Tag - метка лабы, не привязанная к теме
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	LabCount  int       `json:"lab_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Path - учебный маршрут: упорядоченные лабы из разных тем
+type Path struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+	Items       []PathItem `json:"items"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// PathItem - элемент маршрута: либо заголовок раздела, либо лаба
+type PathItem struct {
+	Section string `json:"section,omitempty"`
+	LabID   int    `json:"lab_id,omitempty"`
+	Lab     *Lab   `json:"lab,omitempty"`
+}
+
+// Comment - комментарий к лабе. Step - индекс команды в Commands, к которой
+// привязан комментарий; ответы (ParentID != nil) собираются в Replies.
+type Comment struct {
+	ID        int        `json:"id"`
+	LabID     int        `json:"lab_id"`
+	ParentID  *int       `json:"parent_id,omitempty"`
+	UserID    int        `json:"user_id"`
+	Author    string     `json:"author"`
+	Step      *int       `json:"step,omitempty"`
+	Body      string     `json:"body"`
+	HTML      string     `json:"html"`
+	Hidden    bool       `json:"hidden"`
+	Deleted   bool       `json:"deleted"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Replies   []*Comment `json:"replies"`
+}
+
+// Report - сообщение читателя о проблеме в лабе. FixedByEventID - событие
+// аудита lab.update со снимком лабы, в котором проблема исправлена.
+type Report struct {
+	ID             int       `json:"id"`
+	LabID          int       `json:"lab_id"`
+	LabTitle       string    `json:"lab_title"`
+	UserID         int       `json:"user_id"`
+	Reporter       string    `json:"reporter"`
+	Type           string    `json:"type"`
+	Description    string    `json:"description"`
+	Environment    string    `json:"environment"`
+	Status         string    `json:"status"`
+	FixedByEventID *int64    `json:"fixed_by_event_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Типы сообщений о проблемах
+const (
+	ReportCommandFails = "command_fails"
+	ReportOutdated     = "outdated"
+	ReportTypo         = "typo"
+)
+
+// Статусы сообщений о проблемах
+const (
+	ReportOpen         = "open"
+	ReportAcknowledged = "acknowledged"
+	ReportFixed        = "fixed"
+	ReportWontFix      = "wontfix"
+)
+
+// ReportFilter - фильтры списка сообщений; нулевые значения не фильтруют
+type ReportFilter struct {
+	LabID  int
+	Status string
+	Type   string
+	Limit  int
+	Offset int
+}
+
+// Bookmark - лаба в закладках пользователя
+type Bookmark struct {
+	LabID     int       `json:"lab_id"`
+	Lab       *Lab      `json:"lab,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Collection - именованная подборка лаб пользователя. Непустой ShareToken
+// открывает подборку на чтение по ссылке /c/:token.
+type Collection struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Owner      string    `json:"owner"`
+	Name       string    `json:"name"`
+	ShareToken string    `json:"share_token,omitempty"`
+	LabIDs     []int     `json:"lab_ids"`
+	Labs       []Lab     `json:"labs"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Progress - отметка о прохождении лабы целиком (Step == nil) или шага Commands[Step]
+type Progress struct {
+	LabID       int       `json:"lab_id"`
+	Step        *int      `json:"step,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// TopicProgress - доля пройденных опубликованных лаб темы
+type TopicProgress struct {
+	UserID     int     `json:"user_id,omitempty"`
+	Username   string  `json:"username,omitempty"`
+	TopicSlug  string  `json:"topic_slug"`
+	TopicTitle string  `json:"topic_title"`
+	Completed  int     `json:"completed"`
+	Total      int     `json:"total"`
+	Percent    float64 `json:"percent"`
+}
+
+// LabQuery - параметры выборки GET /api/labs
+type LabQuery struct {
+	TopicSlug     string
+	Difficulty    string
+	Author        string // username автора
+	Tag           string // slug тега
+	Sort          string // title, created_at, updated_at, difficulty, rating
+	Desc          bool
+	Limit         int
+	Cursor        string // непрозрачный курсор из предыдущей страницы
+	PublishedOnly bool
 }
 
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"-"` // хеш пароля
-	IsAdmin  bool   `json:"is_admin"`
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	Password    string `json:"-"` // хеш пароля
+	IsAdmin     bool   `json:"is_admin"`
+	Role        string `json:"role"`
+	OIDCSubject string `json:"-"`
+	Email       string `json:"email,omitempty"`
+}
+
+// Ограничения паролей локальных учёток: при регистрации и для -create-admin
+const (
+	MinPasswordLength = 8
+	// MaxPasswordBytes - bcrypt учитывает только первые 72 байта пароля
+	MaxPasswordBytes = 72
+)
+
+// InviteCode - одноразовый код для регистрации в режиме по приглашениям
+type InviteCode struct {
+	Code      string     `json:"code"`
+	CreatedBy int        `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UsedBy    *int       `json:"used_by,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// Роли пользователей, по возрастанию привилегий
+const (
+	RoleReader   = "reader"
+	RoleEditor   = "editor"
+	RoleReviewer = "reviewer"
+	RoleAdmin    = "admin"
+)
+
+// RoleRank возвращает уровень роли; неизвестная роль равна 0
+func RoleRank(role string) int {
+	switch role {
+	case RoleReader:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleReviewer:
+		return 3
+	case RoleAdmin:
+		return 4
+	}
+	return 0
+}
+
+// Статусы лабы в процессе публикации
+const (
+	LabStatusDraft     = "draft"
+	LabStatusInReview  = "in_review"
+	LabStatusScheduled = "scheduled" // одобрена, ждёт publish_at
+	LabStatusPublished = "published"
+	LabStatusArchived  = "archived"
+)
+
+// LabReview - запись истории ревью: отправка, одобрение, запрос правок
+type LabReview struct {
+	ID        int       `json:"id"`
+	LabID     int       `json:"lab_id"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Action    string    `json:"action"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type SystemMetrics struct {
@@ -37,6 +325,33 @@ type SystemMetrics struct {
 	Timestamp   int64   `json:"timestamp"`
 }
 
+// AuditEvent - запись журнала аудита
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	ActorID    *int            `json:"actor_id"`
+	ActorName  string          `json:"actor_name"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter - фильтры выборки журнала аудита; нулевые значения не фильтруют
+type AuditFilter struct {
+	ActorID    int
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
 type Session struct {
 	Token     string
 	UserID    int
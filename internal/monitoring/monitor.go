@@ -2,11 +2,11 @@ package monitoring
 
 import (
 	"database/sql"
+	"devops-manual/internal/config"
 	"devops-manual/internal/models"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -21,10 +21,10 @@ type Monitor struct {
 	db       *sql.DB
 }
 
-func NewMonitor(db *sql.DB) *Monitor {
+func NewMonitor(db *sql.DB, cfg config.Telegram) *Monitor {
 	return &Monitor{
-		botToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
-		chatID:   os.Getenv("TELEGRAM_CHAT_ID"),
+		botToken: cfg.BotToken,
+		chatID:   cfg.ChatID,
 		db:       db,
 	}
 }
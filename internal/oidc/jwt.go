@@ -0,0 +1,241 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type keySet struct {
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// verifyIDToken проверяет подпись (RS256/ES256), iss, aud, exp и nonce
+func (p *Provider) verifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("oidc: malformed id_token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: id_token header: %w", err)
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: id_token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch header.Alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("oidc: key %q is not RSA", header.Kid)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, fmt.Errorf("oidc: invalid id_token signature")
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return nil, fmt.Errorf("oidc: key %q is not P-256", header.Kid)
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return nil, fmt.Errorf("oidc: invalid id_token signature")
+		}
+	default:
+		return nil, fmt.Errorf("oidc: unsupported id_token alg %q", header.Alg)
+	}
+
+	var payload map[string]interface{}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("oidc: id_token payload: %w", err)
+	}
+
+	if iss, _ := payload["iss"].(string); strings.TrimRight(iss, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: id_token issuer mismatch")
+	}
+	if !audienceContains(payload["aud"], p.cfg.ClientID) {
+		return nil, fmt.Errorf("oidc: id_token audience mismatch")
+	}
+	exp, _ := payload["exp"].(float64)
+	if time.Now().After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return nil, fmt.Errorf("oidc: id_token expired")
+	}
+	if n, _ := payload["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("oidc: id_token nonce mismatch")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("oidc: id_token claims: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("oidc: id_token has no sub")
+	}
+	claims.Groups = stringList(payload[p.cfg.GroupsClaim])
+	return &claims, nil
+}
+
+// key ищет ключ по kid; при промахе JWKS перечитывается (ротация ключей на IdP)
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	ks := p.keys
+	p.mu.Unlock()
+
+	if ks != nil {
+		if k, ok := ks.lookup(kid); ok {
+			return k, nil
+		}
+		if time.Since(ks.fetchedAt) < 30*time.Second {
+			return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+		}
+	}
+
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &doc); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	ks = &keySet{keys: make(map[string]crypto.PublicKey), fetchedAt: time.Now()}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			ks.keys[k.Kid] = pub
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = ks
+	p.mu.Unlock()
+
+	if k, ok := ks.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+}
+
+func (ks *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if k, ok := ks.keys[kid]; ok {
+		return k, true
+	}
+	// Токен без kid допустим, если у IdP ровно один ключ
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	for _, a := range stringList(aud) {
+		if a == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList приводит claim (строку или массив строк) к []string
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"devops-manual/internal/config"
+	"devops-manual/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discovery - документ /.well-known/openid-configuration
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Config - параметры подключения к IdP
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	// RoleMap - соответствие группы IdP локальной роли (group -> role)
+	RoleMap map[string]string
+}
+
+// Provider реализует authorization code flow с PKCE.
+// Discovery и JWKS загружаются лениво и кешируются.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      *keySet
+	pending   map[string]*pendingAuth
+}
+
+type pendingAuth struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+// Claims - проверенные данные из ID токена
+type Claims struct {
+	Subject           string   `json:"sub"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+	Name              string   `json:"name"`
+	Groups            []string `json:"-"`
+}
+
+// NewFromConfig возвращает nil, если issuer не задан (SSO выключен)
+func NewFromConfig(c config.OIDC) *Provider {
+	if c.Issuer == "" {
+		return nil
+	}
+
+	cfg := Config{
+		Issuer:       strings.TrimRight(c.Issuer, "/"),
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectURL,
+		Scopes:       strings.Fields(strings.ReplaceAll(c.Scopes, ",", " ")),
+		GroupsClaim:  c.GroupsClaim,
+		RoleMap:      ParseRoleMap(c.RoleMap),
+	}
+	return New(cfg, nil)
+}
+
+// New создаёт провайдер; client == nil означает http.Client с таймаутом 10s
+func New(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &Provider{
+		cfg:     cfg,
+		client:  client,
+		pending: make(map[string]*pendingAuth),
+	}
+}
+
+// ParseRoleMap разбирает строку вида "devops-admins=admin,devops-editors=editor"
+func ParseRoleMap(s string) map[string]string {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || group == "" || role == "" {
+			continue
+		}
+		m[strings.TrimSpace(group)] = strings.TrimSpace(role)
+	}
+	return m
+}
+
+// Discover загружает discovery документ issuer'а
+func (p *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d Discovery
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(d.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch: %q != %q", d.Issuer, p.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery: incomplete document")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// AuthCodeURL генерирует state, nonce и PKCE verifier и возвращает URL для редиректа на IdP
+func (p *Provider) AuthCodeURL(ctx context.Context) (string, string, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state := randomString(24)
+	verifier := randomString(48)
+	nonce := randomString(24)
+
+	p.mu.Lock()
+	p.cleanupPending()
+	p.pending[state] = &pendingAuth{
+		verifier:  verifier,
+		nonce:     nonce,
+		expiresAt: time.Now().Add(10 * time.Minute),
+	}
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), state, nil
+}
+
+// Exchange обменивает code на токены и возвращает проверенные claims ID токена
+func (p *Provider) Exchange(ctx context.Context, state, code string) (*Claims, error) {
+	p.mu.Lock()
+	pa, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+	if !ok || pa.expiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("oidc: unknown or expired state")
+	}
+
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {pa.verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("oidc token endpoint: %s %s (status %d)", tok.Error, tok.ErrorDescription, resp.StatusCode)
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("oidc: token response has no id_token")
+	}
+
+	return p.verifyIDToken(ctx, tok.IDToken, pa.nonce)
+}
+
+// Role возвращает локальную роль по группам пользователя.
+// Если подходит несколько групп, выигрывает самая привилегированная роль.
+func (p *Provider) Role(groups []string) string {
+	role := models.RoleReader
+	for _, g := range groups {
+		if r, ok := p.cfg.RoleMap[g]; ok && models.RoleRank(r) > models.RoleRank(role) {
+			role = r
+		}
+	}
+	return role
+}
+
+func (p *Provider) cleanupPending() {
+	now := time.Now()
+	for state, pa := range p.pending {
+		if pa.expiresAt.Before(now) {
+			delete(p.pending, state)
+		}
+	}
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
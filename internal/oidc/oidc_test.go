@@ -0,0 +1,326 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "devops-manual"
+	testClientSecret = "s3cret"
+)
+
+// mockIdP - минимальный OpenID провайдер: discovery, JWKS и token endpoint с PKCE
+type mockIdP struct {
+	t      *testing.T
+	srv    *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu        sync.Mutex
+	alg       string
+	discovery int
+	codes     map[string]issuedCode
+}
+
+type issuedCode struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{t: t, rsaKey: rsaKey, ecKey: ecKey, alg: "RS256", codes: map[string]issuedCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.serveDiscovery)
+	mux.HandleFunc("/jwks", idp.serveJWKS)
+	mux.HandleFunc("/token", idp.serveToken)
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *mockIdP) provider() *Provider {
+	return New(Config{
+		Issuer:       idp.srv.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "https://manual.example.com/auth/oidc/callback",
+		Scopes:       []string{"openid", "profile"},
+		RoleMap:      map[string]string{"devops-editors": "editor", "devops-admins": "admin"},
+	}, idp.srv.Client())
+}
+
+func (idp *mockIdP) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	idp.discovery++
+	idp.mu.Unlock()
+	json.NewEncoder(w).Encode(Discovery{
+		Issuer:                idp.srv.URL,
+		AuthorizationEndpoint: idp.srv.URL + "/authorize",
+		TokenEndpoint:         idp.srv.URL + "/token",
+		JWKSURI:               idp.srv.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdP) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	pub := idp.ecKey.PublicKey
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": {
+		{Kty: "RSA", Kid: "rsa-1", Use: "sig", N: b64(idp.rsaKey.N.Bytes()), E: b64(big.NewInt(int64(idp.rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec-1", Use: "sig", Crv: "P-256", X: b64(pub.X.FillBytes(make([]byte, 32))), Y: b64(pub.Y.FillBytes(make([]byte, 32)))},
+		// Ключ шифрования не должен использоваться для подписи
+		{Kty: "RSA", Kid: "enc-1", Use: "enc", N: b64(idp.rsaKey.N.Bytes()), E: "AQAB"},
+	}})
+}
+
+func (idp *mockIdP) serveToken(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+	user, pass, _ := r.BasicAuth()
+	if user != testClientID || pass != testClientSecret {
+		tokenError("invalid_client")
+		return
+	}
+	r.ParseForm()
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("client_id") != testClientID {
+		tokenError("invalid_request")
+		return
+	}
+
+	idp.mu.Lock()
+	issued, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	alg := idp.alg
+	idp.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != issued.challenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idp.sign(alg, idp.claims(issued.nonce)),
+	})
+}
+
+// authorize имитирует вход пользователя на IdP и возвращает code для redirect
+func (idp *mockIdP) authorize(authURL string) (state, code string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientID || q.Get("response_type") != "code" {
+		idp.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	code = randomString(16)
+	idp.mu.Lock()
+	idp.codes[code] = issuedCode{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	idp.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func (idp *mockIdP) claims(nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                idp.srv.URL,
+		"aud":                []string{testClientID, "other"},
+		"sub":                "user-42",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"nonce":              nonce,
+		"email":              "ivan@example.com",
+		"preferred_username": "ivan",
+		"groups":             []string{"devops-editors", "devops-admins"},
+	}
+}
+
+// sign собирает JWT; alg определяет ключ (RS256 - RSA, ES256 - P-256)
+func (idp *mockIdP) sign(alg string, claims map[string]interface{}) string {
+	kid := map[string]string{"RS256": "rsa-1", "ES256": "ec-1"}[alg]
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		s, err := rsa.SignPKCS1v15(rand.Reader, idp.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			idp.t.Fatal(err)
+		}
+		sig = s
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, idp.ecKey, digest[:])
+		if err != nil {
+			idp.t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestDiscover(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	d, err := p.Discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.TokenEndpoint != idp.srv.URL+"/token" || d.JWKSURI != idp.srv.URL+"/jwks" {
+		t.Errorf("unexpected discovery %+v", d)
+	}
+	if _, err := p.Discover(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if idp.discovery != 1 {
+		t.Errorf("discovery fetched %d times, want 1 (cached)", idp.discovery)
+	}
+
+	// Документ чужого issuer'а не принимается
+	other := New(Config{Issuer: idp.srv.URL + "/realms/other", ClientID: testClientID}, idp.srv.Client())
+	if _, err := other.Discover(ctx); err == nil {
+		t.Error("Discover accepted a document for another issuer")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256"} {
+		t.Run(alg, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.alg = alg
+			p := idp.provider()
+			ctx := context.Background()
+
+			authURL, state, err := p.AuthCodeURL(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotState, code := idp.authorize(authURL)
+			if gotState != state {
+				t.Fatalf("state in URL = %q, want %q", gotState, state)
+			}
+
+			claims, err := p.Exchange(ctx, state, code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "user-42" || claims.Email != "ivan@example.com" || claims.PreferredUsername != "ivan" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+			if role := p.Role(claims.Groups); role != "admin" {
+				t.Errorf("Role(%v) = %q, want admin", claims.Groups, role)
+			}
+
+			// state одноразовый
+			if _, err := p.Exchange(ctx, state, code); err == nil {
+				t.Error("state was accepted twice")
+			}
+		})
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	first, _, err := p.AuthCodeURL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, code := idp.authorize(first)
+	_, otherState, err := p.AuthCodeURL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// code выдан под challenge первого входа, verifier второго не подходит
+	_, err = p.Exchange(ctx, otherState, code)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("err = %v, want invalid_grant", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+	const nonce = "n-0S6_WzA2Mj"
+
+	tamper := func(token string) string {
+		parts := strings.Split(token, ".")
+		claims := idp.claims(nonce)
+		claims["sub"] = "admin"
+		payload, _ := json.Marshal(claims)
+		parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+		return strings.Join(parts, ".")
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := idp.claims(nonce)
+		claims[key] = value
+		return claims
+	}
+	foreign := newMockIdP(t)
+	payload, _ := json.Marshal(idp.claims(nonce))
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "."
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"RS256 tampered payload", tamper(idp.sign("RS256", idp.claims(nonce))), "invalid id_token signature"},
+		{"ES256 tampered payload", tamper(idp.sign("ES256", idp.claims(nonce))), "invalid id_token signature"},
+		{"signed by another IdP", foreign.sign("RS256", idp.claims(nonce)), "invalid id_token signature"},
+		{"wrong audience", idp.sign("RS256", with("aud", "someone-else")), "audience mismatch"},
+		{"wrong issuer", idp.sign("ES256", with("iss", "https://evil.example.com")), "issuer mismatch"},
+		{"expired", idp.sign("RS256", with("exp", time.Now().Add(-time.Hour).Unix())), "expired"},
+		{"wrong nonce", idp.sign("ES256", with("nonce", "replayed")), "nonce mismatch"},
+		{"no subject", idp.sign("RS256", with("sub", "")), "no sub"},
+		{"alg none", unsigned, "unsupported id_token alg"},
+		{"malformed", "not-a-jwt", "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.verifyIDToken(ctx, tt.token, nonce)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := p.verifyIDToken(ctx, idp.sign("ES256", idp.claims(nonce)), nonce); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+}
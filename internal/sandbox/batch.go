@@ -0,0 +1,129 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StepResult - результат одной команды пакетного запуска
+type StepResult struct {
+	Step     int    `json:"step"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+	// Done - команда завершилась; false, если оболочка вышла раньше или истёк таймаут
+	Done bool `json:"done"`
+}
+
+// stepMarker отделяет вывод шагов в пакетном режиме
+const stepMarker = "@@devops-manual-step"
+
+// maxBatchOutput - предел вывода пакетного запуска, остальное отбрасывается
+const maxBatchOutput = 1 << 20
+
+// batchScript - скрипт, выполняющий команды по очереди в одной оболочке
+// (cd и export сохраняются между шагами) и печатающий маркеры с кодом выхода
+func batchScript(commands []string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\n")
+	fmt.Fprintf(&b, "__step() { echo \"%s $1 begin\"; eval \"$2\" </dev/null; __rc=$?; printf '\\n%s %%d exit %%d\\n' \"$1\" \"$__rc\"; }\n",
+		stepMarker, stepMarker)
+	for i, cmd := range commands {
+		fmt.Fprintf(&b, "__step %d '%s'\n", i, strings.ReplaceAll(cmd, "'", `'\''`))
+	}
+	return b.String()
+}
+
+// parseBatch разбирает вывод batchScript на шаги
+func parseBatch(out string, n int) []StepResult {
+	results := make([]StepResult, n)
+	for i := range results {
+		results[i].Step = i
+	}
+
+	current := -1
+	var buf []string
+	for _, line := range strings.Split(strings.ReplaceAll(out, "\r", ""), "\n") {
+		if !strings.HasPrefix(line, stepMarker+" ") {
+			if current >= 0 {
+				buf = append(buf, line)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		step, err := strconv.Atoi(fields[1])
+		if err != nil || step < 0 || step >= n {
+			continue
+		}
+		switch fields[2] {
+		case "begin":
+			current, buf = step, nil
+		case "exit":
+			if len(fields) == 4 {
+				results[step].ExitCode, _ = strconv.Atoi(fields[3])
+				results[step].Done = true
+			}
+			results[step].Output = strings.TrimRight(strings.Join(buf, "\n"), "\n")
+			current, buf = -1, nil
+		}
+	}
+	// Шаг, на котором всё оборвалось, сохраняет вывод до обрыва
+	if current >= 0 {
+		results[current].Output = strings.TrimRight(strings.Join(buf, "\n"), "\n")
+	}
+	return results
+}
+
+// RunBatch выполняет команды в одноразовом контейнере и возвращает результат
+// каждого шага. Занимает слот общего лимита Manager на время выполнения.
+func (m *Manager) RunBatch(ctx context.Context, files []File, commands []string, timeout time.Duration) ([]StepResult, error) {
+	m.mu.Lock()
+	if len(m.sessions) >= m.limits.Total {
+		m.mu.Unlock()
+		return nil, ErrCapacity
+	}
+	slot := fmt.Sprintf("batch-%d", time.Now().UnixNano())
+	m.sessions[slot] = &tracked{}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.sessions, slot)
+		m.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	spec := m.base
+	spec.Files = append(append([]File{}, m.base.Files...), files...)
+	spec.Labels = map[string]string{Label: "1", Label + ".batch": "1"}
+	spec.Batch = commands
+
+	sess, err := m.runner.Start(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	// Слот переносим на ID контейнера: cleanup не трогает контейнеры из m.sessions,
+	// а прогон может идти дольше, чем живут безымянные контейнеры
+	m.mu.Lock()
+	delete(m.sessions, slot)
+	slot = sess.ID()
+	m.sessions[slot] = &tracked{}
+	m.mu.Unlock()
+	// Закрытие сессии по таймауту прерывает чтение ниже
+	go func() {
+		<-ctx.Done()
+		sess.Close()
+	}()
+
+	out, _ := io.ReadAll(io.LimitReader(sess, maxBatchOutput))
+	sess.Close()
+	return parseBatch(string(out), len(commands)), nil
+}
@@ -0,0 +1,290 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dockerAPI - версия Engine API; поддерживается Docker 20.10 и новее
+const dockerAPI = "/v1.41"
+
+// DockerRunner запускает контейнеры через Docker Engine API на локальном сокете.
+// Образ должен быть скачан заранее (docker pull), сам раннер его не тянет.
+type DockerRunner struct {
+	socket string
+	client *http.Client
+}
+
+// NewDockerRunner создаёт раннер; пустой socket - /var/run/docker.sock
+func NewDockerRunner(socket string) *DockerRunner {
+	if socket == "" {
+		socket = "/var/run/docker.sock"
+	}
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	return &DockerRunner{
+		socket: socket,
+		client: &http.Client{Transport: &http.Transport{DialContext: dial}},
+	}
+}
+
+// do выполняет запрос к API; out == nil - тело ответа игнорируется
+func (r *DockerRunner) do(ctx context.Context, method, path string, body io.Reader, contentType string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://docker"+dockerAPI+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("docker %s %s: %d %s", method, path, resp.StatusCode, apiErr.Message)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func (r *DockerRunner) doJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	return r.do(ctx, method, path, body, "application/json", out)
+}
+
+func (r *DockerRunner) Start(ctx context.Context, spec Spec) (Session, error) {
+	if len(spec.Batch) > 0 {
+		spec.Files = append(spec.Files, File{Path: "/tmp/lab-batch.sh", Mode: 0755, Data: []byte(batchScript(spec.Batch))})
+		spec.Shell = []string{"/bin/bash", "/tmp/lab-batch.sh"}
+	}
+
+	network := "none"
+	if spec.Network {
+		network = "bridge"
+	}
+	memory := spec.MemoryMB * 1024 * 1024
+
+	create := map[string]interface{}{
+		"Image":        spec.Image,
+		"Cmd":          spec.Shell,
+		"WorkingDir":   spec.Workdir,
+		"Env":          spec.Env,
+		"Labels":       spec.Labels,
+		"Tty":          true,
+		"OpenStdin":    true,
+		"AttachStdin":  true,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"HostConfig": map[string]interface{}{
+			"Memory":      memory,
+			"MemorySwap":  memory, // без свопа
+			"NanoCpus":    int64(spec.CPUs * 1e9),
+			"PidsLimit":   spec.PidsLimit,
+			"NetworkMode": network,
+			"CapDrop":     []string{"ALL"},
+			"CapAdd":      []string{"CHOWN", "DAC_OVERRIDE", "FOWNER", "SETUID", "SETGID"},
+			"SecurityOpt": []string{"no-new-privileges"},
+			"AutoRemove":  true,
+		},
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := r.doJSON(ctx, http.MethodPost, "/containers/create", create, &created); err != nil {
+		return nil, err
+	}
+
+	s, err := r.prepare(ctx, created.ID, spec)
+	if err != nil {
+		r.remove(created.ID)
+		return nil, err
+	}
+	return s, nil
+}
+
+// prepare кладёт файлы лабы, подключается к TTY и запускает контейнер
+func (r *DockerRunner) prepare(ctx context.Context, id string, spec Spec) (*dockerSession, error) {
+	if len(spec.Files) > 0 {
+		archive, err := tarFiles(spec.Files)
+		if err != nil {
+			return nil, err
+		}
+		err = r.do(ctx, http.MethodPut, "/containers/"+id+"/archive?path=/", archive, "application/x-tar", nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Подключаемся до старта, чтобы не потерять первый вывод оболочки
+	s, err := r.attach(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.doJSON(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil); err != nil {
+		s.conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// attach открывает двунаправленный поток TTY (HTTP upgrade на сокете Docker)
+func (r *DockerRunner) attach(ctx context.Context, id string) (*dockerSession, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", r.socket)
+	if err != nil {
+		return nil, err
+	}
+
+	req := fmt.Sprintf("POST %s/containers/%s/attach?stream=1&stdin=1&stdout=1&stderr=1 HTTP/1.1\r\n"+
+		"Host: docker\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n", dockerAPI, id)
+	if _, err := io.WriteString(conn, req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("docker attach: %s", resp.Status)
+	}
+
+	return &dockerSession{id: id, conn: conn, r: br, runner: r}, nil
+}
+
+// remove удаляет контейнер принудительно; уже удалённый (AutoRemove) - не ошибка
+func (r *DockerRunner) remove(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	err := r.do(ctx, http.MethodDelete, "/containers/"+id+"?force=1", nil, "", nil)
+	if err != nil && strings.Contains(err.Error(), ": 404 ") {
+		return nil
+	}
+	return err
+}
+
+func (r *DockerRunner) Cleanup(ctx context.Context, keep map[string]bool, olderThan time.Duration) (int, error) {
+	filters, _ := json.Marshal(map[string][]string{"label": {Label}})
+	var list []struct {
+		ID      string `json:"Id"`
+		Created int64  `json:"Created"`
+	}
+	err := r.doJSON(ctx, http.MethodGet, "/containers/json?all=1&filters="+url.QueryEscape(string(filters)), nil, &list)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, c := range list {
+		if keep[c.ID] || time.Since(time.Unix(c.Created, 0)) < olderThan {
+			continue
+		}
+		if err := r.remove(c.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// tarFiles упаковывает файлы для PUT /containers/:id/archive?path=/
+func tarFiles(files []File) (io.Reader, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	dirs := map[string]bool{}
+	for _, f := range files {
+		// Родительские каталоги создаём явно, например /root/lab
+		name := strings.TrimPrefix(f.Path, "/")
+		for i := strings.Index(name, "/"); i > 0; i = next(name, i) {
+			dir := name[:i+1]
+			if dirs[dir] {
+				continue
+			}
+			dirs[dir] = true
+			hdr := &tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755, ModTime: time.Now()}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, err
+			}
+		}
+
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    mode,
+			Size:    int64(len(f.Data)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// next возвращает позицию следующего "/" после i или -1
+func next(s string, i int) int {
+	j := strings.Index(s[i+1:], "/")
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+type dockerSession struct {
+	id     string
+	conn   net.Conn
+	r      *bufio.Reader
+	runner *DockerRunner
+}
+
+func (s *dockerSession) ID() string                  { return s.id }
+func (s *dockerSession) Read(p []byte) (int, error)  { return s.r.Read(p) }
+func (s *dockerSession) Write(p []byte) (int, error) { return s.conn.Write(p) }
+
+// Close отключается от TTY и удаляет контейнер
+func (s *dockerSession) Close() error {
+	s.conn.Close()
+	return s.runner.remove(s.id)
+}
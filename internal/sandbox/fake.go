@@ -0,0 +1,125 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeRunner имитирует контейнер без Docker: построчно отвечает на ввод.
+// Используется для разработки интерфейса (SANDBOX_RUNNER=fake) и тестов.
+type FakeRunner struct {
+	// Exec задаёт результат команды в пакетном режиме;
+	// по умолчанию команда "выполняется" успешно и выводит сама себя
+	Exec func(cmd string) (output string, exitCode int)
+
+	mu       sync.Mutex
+	seq      int
+	started  map[string]Spec
+	sessions map[string]*fakeSession
+}
+
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{started: map[string]Spec{}, sessions: map[string]*fakeSession{}}
+}
+
+func (r *FakeRunner) Start(ctx context.Context, spec Spec) (Session, error) {
+	r.mu.Lock()
+	r.seq++
+	id := fmt.Sprintf("fake-%d", r.seq)
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := &fakeSession{id: id, in: inW, out: outR, runner: r}
+	r.started[id] = spec
+	r.sessions[id] = s
+	r.mu.Unlock()
+
+	if len(spec.Batch) > 0 {
+		go r.batch(outW, spec.Batch)
+		return s, nil
+	}
+
+	go func() {
+		defer outW.Close()
+		fmt.Fprintf(outW, "fake sandbox %s (%s)\r\n", id, spec.Image)
+		for _, f := range spec.Files {
+			fmt.Fprintf(outW, "seeded %s (%d bytes)\r\n", f.Path, len(f.Data))
+		}
+		fmt.Fprint(outW, "$ ")
+		sc := bufio.NewScanner(inR)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			switch {
+			case line == "exit":
+				fmt.Fprint(outW, "exit\r\n")
+				return
+			case line != "":
+				fmt.Fprintf(outW, "%s\r\nfake: %s\r\n", line, line)
+			}
+			fmt.Fprint(outW, "$ ")
+		}
+	}()
+	return s, nil
+}
+
+// batch пишет вывод в формате batchScript
+func (r *FakeRunner) batch(out *io.PipeWriter, commands []string) {
+	defer out.Close()
+	for i, cmd := range commands {
+		output, code := cmd, 0
+		if r.Exec != nil {
+			output, code = r.Exec(cmd)
+		}
+		fmt.Fprintf(out, "%s %d begin\r\n%s\r\n%s %d exit %d\r\n", stepMarker, i, output, stepMarker, i, code)
+	}
+}
+
+// Cleanup закрывает сессии, не входящие в keep, как docker rm -f обрывает
+// контейнер; olderThan фейк не учитывает - все его сессии считаются старыми
+func (r *FakeRunner) Cleanup(ctx context.Context, keep map[string]bool, olderThan time.Duration) (int, error) {
+	r.mu.Lock()
+	var orphaned []*fakeSession
+	for id := range r.started {
+		if !keep[id] {
+			orphaned = append(orphaned, r.sessions[id])
+		}
+	}
+	r.mu.Unlock()
+
+	for _, s := range orphaned {
+		s.Close()
+	}
+	return len(orphaned), nil
+}
+
+// Running - число незакрытых фейковых контейнеров
+func (r *FakeRunner) Running() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.started)
+}
+
+type fakeSession struct {
+	id     string
+	in     *io.PipeWriter
+	out    *io.PipeReader
+	runner *FakeRunner
+}
+
+func (s *fakeSession) ID() string                  { return s.id }
+func (s *fakeSession) Read(p []byte) (int, error)  { return s.out.Read(p) }
+func (s *fakeSession) Write(p []byte) (int, error) { return s.in.Write(p) }
+
+func (s *fakeSession) Close() error {
+	s.in.Close()
+	s.out.Close()
+	s.runner.mu.Lock()
+	delete(s.runner.started, s.id)
+	delete(s.runner.sessions, s.id)
+	s.runner.mu.Unlock()
+	return nil
+}
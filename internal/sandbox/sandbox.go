@@ -0,0 +1,295 @@
+// Package sandbox запускает изолированные терминалы для выполнения команд лаб.
+// Контейнеры создаёт Runner (Docker или фейковый для разработки), Manager
+// следит за лимитами на пользователя, простоем и временем жизни сессий.
+package sandbox
+
+import (
+	"context"
+	"devops-manual/internal/config"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManySessions - пользователь исчерпал лимит одновременных терминалов
+	ErrTooManySessions = errors.New("too many sandbox sessions")
+	// ErrCapacity - исчерпан общий лимит терминалов на сервере
+	ErrCapacity = errors.New("sandbox capacity exhausted")
+)
+
+// File - файл, который кладётся в контейнер до старта оболочки
+type File struct {
+	Path string
+	Mode int64
+	Data []byte
+}
+
+// Spec описывает контейнер для одной сессии
+type Spec struct {
+	Image     string
+	Shell     []string
+	Workdir   string
+	Files     []File
+	Env       []string
+	Labels    map[string]string
+	MemoryMB  int64
+	CPUs      float64
+	PidsLimit int64
+	// Network - разрешить сеть (по умолчанию контейнер без сети)
+	Network bool
+	// Batch - выполнить команды без интерактивной оболочки (см. RunBatch)
+	Batch []string
+}
+
+// Session - подключённый терминал: Read отдаёт вывод TTY, Write - ввод
+type Session interface {
+	io.ReadWriteCloser
+	ID() string
+}
+
+// Runner создаёт и удаляет контейнеры
+type Runner interface {
+	Start(ctx context.Context, spec Spec) (Session, error)
+	// Cleanup удаляет контейнеры с меткой Label старше olderThan, не входящие
+	// в keep (остались после падения процесса или потерянных сессий)
+	Cleanup(ctx context.Context, keep map[string]bool, olderThan time.Duration) (int, error)
+}
+
+// Label - метка контейнеров, созданных этим сервером
+const Label = "devops-manual.sandbox"
+
+// Limits - ограничения Manager; нулевые значения заменяются значениями по умолчанию
+type Limits struct {
+	PerUser     int
+	Total       int
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+}
+
+// Manager выдаёт сессии с учётом лимитов и закрывает простаивающие
+type Manager struct {
+	runner Runner
+	limits Limits
+	base   Spec
+
+	mu       sync.Mutex
+	sessions map[string]*tracked
+}
+
+type tracked struct {
+	Session
+	userID  int
+	started time.Time
+
+	mu         sync.Mutex
+	lastActive time.Time
+	closeOnce  sync.Once
+	release    func()
+}
+
+func (t *tracked) touch() {
+	t.mu.Lock()
+	t.lastActive = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *tracked) idle() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Since(t.lastActive)
+}
+
+func (t *tracked) Read(p []byte) (int, error) {
+	n, err := t.Session.Read(p)
+	if n > 0 {
+		t.touch()
+	}
+	return n, err
+}
+
+func (t *tracked) Write(p []byte) (int, error) {
+	t.touch()
+	return t.Session.Write(p)
+}
+
+// Close освобождает слот пользователя и удаляет контейнер; повторный вызов безопасен
+func (t *tracked) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		err = t.Session.Close()
+		t.release()
+	})
+	return err
+}
+
+// NewManager создаёт менеджер; base - общие параметры контейнера
+// (образ, лимиты ресурсов), к которым Open добавляет файлы лабы
+func NewManager(runner Runner, base Spec, limits Limits) *Manager {
+	if limits.PerUser <= 0 {
+		limits.PerUser = 1
+	}
+	if limits.Total <= 0 {
+		limits.Total = 20
+	}
+	if limits.IdleTimeout <= 0 {
+		limits.IdleTimeout = 10 * time.Minute
+	}
+	if limits.MaxLifetime <= 0 {
+		limits.MaxLifetime = time.Hour
+	}
+	return &Manager{
+		runner:   runner,
+		limits:   limits,
+		base:     base,
+		sessions: map[string]*tracked{},
+	}
+}
+
+// NewFromConfig собирает менеджер из настроек; nil, если песочница выключена
+func NewFromConfig(cfg config.Sandbox) *Manager {
+	var runner Runner
+	switch kind := cfg.Runner; kind {
+	case "":
+		return nil
+	case "docker":
+		runner = NewDockerRunner(cfg.DockerSocket)
+	case "fake":
+		runner = NewFakeRunner()
+	default:
+		log.Printf("Unknown SANDBOX_RUNNER %q, sandbox disabled", kind)
+		return nil
+	}
+
+	base := Spec{
+		Image:     cfg.Image,
+		Shell:     []string{"/bin/bash"},
+		Workdir:   "/root",
+		MemoryMB:  int64(cfg.MemoryMB),
+		CPUs:      cfg.CPUs,
+		PidsLimit: 128,
+		Network:   cfg.Network,
+	}
+
+	return NewManager(runner, base, Limits{
+		PerUser:     cfg.PerUser,
+		Total:       cfg.Total,
+		IdleTimeout: time.Duration(cfg.IdleMinutes) * time.Minute,
+		MaxLifetime: time.Duration(cfg.MaxMinutes) * time.Minute,
+	})
+}
+
+// Open запускает терминал для пользователя с файлами лабы
+func (m *Manager) Open(ctx context.Context, userID int, files []File) (Session, error) {
+	m.mu.Lock()
+	perUser := 0
+	for _, s := range m.sessions {
+		if s.userID == userID {
+			perUser++
+		}
+	}
+	switch {
+	case perUser >= m.limits.PerUser:
+		m.mu.Unlock()
+		return nil, ErrTooManySessions
+	case len(m.sessions) >= m.limits.Total:
+		m.mu.Unlock()
+		return nil, ErrCapacity
+	}
+	// Резервируем слот до старта контейнера, чтобы параллельные запросы не обошли лимит
+	slot := fmt.Sprintf("pending-%d-%d", userID, time.Now().UnixNano())
+	m.sessions[slot] = &tracked{userID: userID}
+	m.mu.Unlock()
+
+	spec := m.base
+	spec.Files = append(append([]File{}, m.base.Files...), files...)
+	spec.Labels = map[string]string{Label: "1", Label + ".user": strconv.Itoa(userID)}
+
+	sess, err := m.runner.Start(ctx, spec)
+
+	m.mu.Lock()
+	delete(m.sessions, slot)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	t := &tracked{Session: sess, userID: userID, started: time.Now(), lastActive: time.Now()}
+	t.release = func() {
+		m.mu.Lock()
+		delete(m.sessions, sess.ID())
+		m.mu.Unlock()
+	}
+	m.sessions[sess.ID()] = t
+	m.mu.Unlock()
+	return t, nil
+}
+
+// Active - число открытых сессий
+func (m *Manager) Active() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// Limits возвращает действующие ограничения
+func (m *Manager) Limits() Limits {
+	return m.limits
+}
+
+// reap закрывает сессии, простаивающие дольше IdleTimeout или живущие дольше MaxLifetime
+func (m *Manager) reap() {
+	m.mu.Lock()
+	var expired []*tracked
+	for _, s := range m.sessions {
+		if s.Session == nil {
+			continue // слот ещё стартует или пакетный запуск со своим таймаутом
+		}
+		if s.idle() > m.limits.IdleTimeout || time.Since(s.started) > m.limits.MaxLifetime {
+			expired = append(expired, s)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, s := range expired {
+		log.Printf("Sandbox: closing session %s of user %d (idle %s)", s.ID(), s.userID, s.idle().Round(time.Second))
+		s.Close()
+	}
+}
+
+// cleanup удаляет контейнеры песочницы, о которых менеджер не знает
+func (m *Manager) cleanup() {
+	m.mu.Lock()
+	keep := map[string]bool{}
+	for id := range m.sessions {
+		keep[id] = true
+	}
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	// Свежие контейнеры не трогаем: они могут ещё регистрироваться в Open
+	n, err := m.runner.Cleanup(ctx, keep, 2*time.Minute)
+	if err != nil {
+		log.Println("ERROR sandbox cleanup:", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Sandbox cleanup: removed %d orphaned containers", n)
+	}
+}
+
+// Start периодически закрывает простаивающие сессии и удаляет осиротевшие контейнеры.
+// Первая очистка выполняется сразу - после рестарта сервера.
+func (m *Manager) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for ; true; <-ticker.C {
+			m.reap()
+			m.cleanup()
+		}
+	}()
+}
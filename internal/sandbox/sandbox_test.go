@@ -0,0 +1,245 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestManager(limits Limits) (*Manager, *FakeRunner) {
+	runner := NewFakeRunner()
+	return NewManager(runner, Spec{Image: "test:latest"}, limits), runner
+}
+
+func TestOpenPerUserLimit(t *testing.T) {
+	m, runner := newTestManager(Limits{PerUser: 1, Total: 10})
+	ctx := context.Background()
+
+	first, err := m.Open(ctx, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Open(ctx, 1, nil); !errors.Is(err, ErrTooManySessions) {
+		t.Fatalf("second Open: err = %v, want ErrTooManySessions", err)
+	}
+	other, err := m.Open(ctx, 2, nil)
+	if err != nil {
+		t.Fatalf("Open for another user: %v", err)
+	}
+	defer other.Close()
+
+	// Закрытая сессия освобождает слот; повторный Close безопасен
+	first.Close()
+	first.Close()
+	if got := runner.Running(); got != 1 {
+		t.Errorf("Running = %d, want 1", got)
+	}
+	again, err := m.Open(ctx, 1, nil)
+	if err != nil {
+		t.Fatalf("Open after Close: %v", err)
+	}
+	again.Close()
+}
+
+func TestOpenTotalLimit(t *testing.T) {
+	m, _ := newTestManager(Limits{PerUser: 5, Total: 2})
+	ctx := context.Background()
+
+	for user := 1; user <= 2; user++ {
+		s, err := m.Open(ctx, user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+	}
+	if _, err := m.Open(ctx, 3, nil); !errors.Is(err, ErrCapacity) {
+		t.Errorf("Open over total: err = %v, want ErrCapacity", err)
+	}
+	if _, err := m.RunBatch(ctx, nil, []string{"true"}, time.Second); !errors.Is(err, ErrCapacity) {
+		t.Errorf("RunBatch over total: err = %v, want ErrCapacity", err)
+	}
+	if got := m.Active(); got != 2 {
+		t.Errorf("Active = %d, want 2", got)
+	}
+}
+
+func TestSessionTerminal(t *testing.T) {
+	m, _ := newTestManager(Limits{})
+	files := []File{{Path: "/root/lab/commands.sh", Data: []byte("ls\n")}}
+	s, err := m.Open(context.Background(), 1, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	out := bufio.NewReader(s)
+	readUntil(t, out, "seeded /root/lab/commands.sh (3 bytes)")
+	// Фейк пишет приглашение синхронно, поэтому ввод отправляем параллельно с чтением
+	go s.Write([]byte("kubectl get pods\n"))
+	readUntil(t, out, "fake: kubectl get pods")
+}
+
+func readUntil(t *testing.T, r *bufio.Reader, want string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if strings.Contains(line, want) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("output ended before %q: %v", want, err)
+		}
+	}
+}
+
+func TestReapIdleAndExpired(t *testing.T) {
+	m, runner := newTestManager(Limits{PerUser: 3, IdleTimeout: time.Minute, MaxLifetime: time.Hour})
+	ctx := context.Background()
+
+	var sessions []*tracked
+	for i := 0; i < 3; i++ {
+		s, err := m.Open(ctx, 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s.(*tracked))
+	}
+	idle, expired, alive := sessions[0], sessions[1], sessions[2]
+	idle.lastActive = time.Now().Add(-2 * time.Minute)
+	expired.started = time.Now().Add(-2 * time.Hour)
+
+	m.reap()
+
+	if got := m.Active(); got != 1 {
+		t.Fatalf("Active after reap = %d, want 1", got)
+	}
+	if got := runner.Running(); got != 1 {
+		t.Errorf("Running after reap = %d, want 1", got)
+	}
+	m.mu.Lock()
+	_, ok := m.sessions[alive.ID()]
+	m.mu.Unlock()
+	if !ok {
+		t.Error("active session was reaped")
+	}
+	alive.Close()
+}
+
+func TestCleanupKeepsKnownSessions(t *testing.T) {
+	m, runner := newTestManager(Limits{})
+	ctx := context.Background()
+
+	// Контейнер, о котором менеджер не знает (остался после рестарта)
+	if _, err := runner.Start(ctx, Spec{}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.Open(ctx, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	m.cleanup()
+
+	if got := runner.Running(); got != 1 {
+		t.Fatalf("Running after cleanup = %d, want 1", got)
+	}
+	runner.mu.Lock()
+	_, ok := runner.started[s.ID()]
+	runner.mu.Unlock()
+	if !ok {
+		t.Error("cleanup removed a session owned by the manager")
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	m, runner := newTestManager(Limits{})
+	runner.Exec = func(cmd string) (string, int) {
+		if cmd == "false" {
+			return "boom", 1
+		}
+		return "ran " + cmd, 0
+	}
+
+	results, err := m.RunBatch(context.Background(), nil, []string{"cd /tmp", "false"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StepResult{
+		{Step: 0, ExitCode: 0, Output: "ran cd /tmp", Done: true},
+		{Step: 1, ExitCode: 1, Output: "boom", Done: true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+	if got := m.Active(); got != 0 {
+		t.Errorf("Active after RunBatch = %d, want 0", got)
+	}
+}
+
+func TestCleanupKeepsRunningBatch(t *testing.T) {
+	m, runner := newTestManager(Limits{})
+	running, release := make(chan struct{}), make(chan struct{})
+	runner.Exec = func(cmd string) (string, int) {
+		if cmd == "sleep 300" {
+			close(running)
+			<-release
+		}
+		return "ran " + cmd, 0
+	}
+
+	type batch struct {
+		results []StepResult
+		err     error
+	}
+	done := make(chan batch)
+	go func() {
+		results, err := m.RunBatch(context.Background(), nil, []string{"sleep 300", "echo ok"}, 5*time.Second)
+		done <- batch{results, err}
+	}()
+
+	// Очистка посреди долгого прогона не должна обрывать его контейнер
+	<-running
+	m.cleanup()
+	if got := runner.Running(); got != 1 {
+		t.Errorf("Running after cleanup = %d, want 1", got)
+	}
+	close(release)
+
+	b := <-done
+	if b.err != nil {
+		t.Fatal(b.err)
+	}
+	for _, r := range b.results {
+		if !r.Done || r.ExitCode != 0 {
+			t.Errorf("step %d = %+v, want finished successfully", r.Step, r)
+		}
+	}
+	if got := m.Active(); got != 0 {
+		t.Errorf("Active after RunBatch = %d, want 0", got)
+	}
+}
+
+func TestParseBatchInterrupted(t *testing.T) {
+	out := stepMarker + " 0 begin\r\nok\r\n" + stepMarker + " 0 exit 0\r\n" +
+		stepMarker + " 1 begin\r\nhalf of the output"
+	results := parseBatch(out, 3)
+
+	if !results[0].Done || results[0].Output != "ok" {
+		t.Errorf("step 0 = %+v", results[0])
+	}
+	if results[1].Done || results[1].Output != "half of the output" {
+		t.Errorf("step 1 = %+v, want interrupted with partial output", results[1])
+	}
+	if results[2].Done || results[2].Output != "" {
+		t.Errorf("step 2 = %+v, want not started", results[2])
+	}
+}
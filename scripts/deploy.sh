@@ -34,7 +34,7 @@ $GO_BIN mod tidy
 $GO_BIN mod download
 
 # Сборка бинарника (лучше чем go run для production)
-$GO_BIN build -o devops-manual cmd/main.go
+$GO_BIN build -o devops-manual ./cmd
 
 # Копирование .env
 if [ ! -f "$PROJECT_DIR/.env" ]; then